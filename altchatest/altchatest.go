@@ -0,0 +1,105 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+// Package altchatest provides helpers for testing applications which use the
+// altcha package and its http middleware.
+package altchatest
+
+import (
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Secret is the fixed secret used by the services created by NewService. It
+// must never be used outside of tests.
+const Secret = "altchatest-fixed-secret-not-for-production"
+
+// Complexity is the complexity of the challenges created by the services from
+// NewService. It is low, so that the challenges are quick to solve.
+const Complexity = 5000
+
+// NewService creates a Service for use in tests. It signs using the fixed
+// Secret, so that it accepts the challenges issued by any other test service,
+// and uses a low complexity. The service is closed when the test finishes.
+func NewService(tb testing.TB) *altcha.Service {
+	tb.Helper()
+	service := altcha.NewService(altcha.Config{
+		Complexity: Complexity,
+		Secrets:    altcha.StaticSecret(Secret),
+	})
+	tb.Cleanup(func() { _ = service.Close() })
+	return service
+}
+
+// SolvedMessage returns a new challenge from the service, which has already
+// been solved. The test fails if the challenge cannot be solved.
+func SolvedMessage(tb testing.TB, service *altcha.Service) altcha.Message {
+	tb.Helper()
+	msg := service.NewChallenge()
+	number, ok := msg.Solve(0)
+	if !ok {
+		tb.Fatalf("altchatest: could not solve challenge %s", msg.String())
+	}
+	msg.Number = number
+	return msg
+}
+
+// SolvedPayload returns a valid response for the service, in the encoding sent
+// by the widget in the altcha form field. See ProtectForm and ProtectJSON.
+func SolvedPayload(tb testing.TB, service *altcha.Service) string {
+	tb.Helper()
+	return SolvedMessage(tb, service).EncodeWithBase64()
+}
+
+// SolvedHeader returns a valid response for the service, in the encoding sent
+// in the Authorization header. See ProtectHeader.
+func SolvedHeader(tb testing.TB, service *altcha.Service) string {
+	tb.Helper()
+	return SolvedMessage(tb, service).String()
+}
+
+// AssertUnauthorized checks the response has a 401 status code, and a new
+// challenge in the WWW-Authenticate header, as written by ProtectHeader when
+// the response to the challenge is missing or invalid.
+func AssertUnauthorized(tb testing.TB, resp *http.Response) {
+	tb.Helper()
+	if resp.StatusCode != http.StatusUnauthorized {
+		tb.Errorf("altchatest: expected status 401 Unauthorized; got %v", resp.StatusCode)
+	}
+	assertChallenge(tb, resp)
+}
+
+// AssertForbidden checks the response has a 403 status code, as written by
+// ProtectForm and ProtectJSON when the response to the challenge is invalid.
+func AssertForbidden(tb testing.TB, resp *http.Response) {
+	tb.Helper()
+	if resp.StatusCode != http.StatusForbidden {
+		tb.Errorf("altchatest: expected status 403 Forbidden; got %v", resp.StatusCode)
+	}
+}
+
+// AssertChallenged checks the response has a 200 status code, and a new
+// challenge in the WWW-Authenticate header, as written by ProtectForm and
+// ProtectJSON when no response to the challenge is sent.
+func AssertChallenged(tb testing.TB, resp *http.Response) {
+	tb.Helper()
+	if resp.StatusCode != http.StatusOK {
+		tb.Errorf("altchatest: expected status 200 OK; got %v", resp.StatusCode)
+	}
+	assertChallenge(tb, resp)
+}
+
+func assertChallenge(tb testing.TB, resp *http.Response) {
+	tb.Helper()
+	header := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(header, altcha.TextPrefix) {
+		tb.Errorf("altchatest: expected a challenge in the WWW-Authenticate header; got %q", header)
+		return
+	}
+	if _, err := altcha.DecodeText(header); err != nil {
+		tb.Errorf("altchatest: could not decode the challenge %q: %v", header, err)
+	}
+}
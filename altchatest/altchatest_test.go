@@ -0,0 +1,115 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altchatest
+
+import (
+	"github.com/k42-software/go-altcha"
+	altchahttp "github.com/k42-software/go-altcha/http"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func TestSolvedPayload(t *testing.T) {
+	service := NewService(t)
+
+	if !service.ValidateResponse(SolvedPayload(t, service), true) {
+		t.Error("Expected solved payload to be valid")
+	}
+	if err := service.VerifyMessage(SolvedMessage(t, service), altcha.VerifyOptions{}); err != nil {
+		t.Errorf("Expected solved message to be valid, got %v", err)
+	}
+
+	// Test services share the fixed secret
+	if !NewService(t).ValidateResponse(SolvedPayload(t, service), true) {
+		t.Error("Expected payload to be accepted by another test service")
+	}
+}
+
+func TestClient(t *testing.T) {
+	protector := &altchahttp.Protector{Service: NewService(t)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/form", protector.ProtectForm(okHandler))
+	mux.Handle("/json", protector.ProtectJSON(okHandler))
+	mux.Handle("/header", protector.ProtectHeader(okHandler))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(t, server)
+
+	resp, err := client.PostForm(server.URL+"/form", url.Values{"name": {"test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("form: expected status 204; got %v", resp.StatusCode)
+	}
+
+	resp, err = client.Post(server.URL+"/json", "application/json", strings.NewReader(`{"name":"test"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("json: expected status 204; got %v", resp.StatusCode)
+	}
+
+	resp, err = client.Get(server.URL + "/header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("header: expected status 204; got %v", resp.StatusCode)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	protector := &altchahttp.Protector{Service: NewService(t)}
+
+	w := httptest.NewRecorder()
+	protector.ProtectHeader(okHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	AssertUnauthorized(t, w.Result())
+
+	w = httptest.NewRecorder()
+	protector.ProtectForm(okHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	AssertChallenged(t, w.Result())
+
+	w = httptest.NewRecorder()
+	protector.ProtectForm(okHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?altcha=invalid", nil))
+	AssertForbidden(t, w.Result())
+
+	// The assertions fail for other outcomes
+	w = httptest.NewRecorder()
+	okHandler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	for name, assert := range map[string]func(testing.TB, *http.Response){
+		"AssertUnauthorized": AssertUnauthorized,
+		"AssertForbidden":    AssertForbidden,
+		"AssertChallenged":   AssertChallenged,
+	} {
+		recorder := &failureRecorder{TB: t}
+		assert(recorder, w.Result())
+		if !recorder.failed {
+			t.Errorf("Expected %s to fail for a 204 response", name)
+		}
+	}
+}
+
+// failureRecorder records failures instead of failing the test.
+type failureRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (recorder *failureRecorder) Helper() {}
+
+func (recorder *failureRecorder) Errorf(string, ...any) {
+	recorder.failed = true
+}
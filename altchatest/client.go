@@ -0,0 +1,23 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altchatest
+
+import (
+	altchahttp "github.com/k42-software/go-altcha/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// NewClient returns a client for the test server, which transparently solves
+// challenges. This works with ProtectForm and ProtectJSON, which send the
+// challenge with a 200 status code, and ProtectHeader, which sends it with a
+// 401 status code. See the Transport in the http package for details.
+func NewClient(tb testing.TB, server *httptest.Server) *http.Client {
+	tb.Helper()
+	client := server.Client()
+	client.Transport = &altchahttp.Transport{Base: client.Transport}
+	return client
+}
@@ -0,0 +1,93 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// Binding is the context of the client which a challenge is bound to. A
+// response to a bound challenge is only accepted when it is verified with the
+// same binding, so that a solution cannot be harvested on one client and used
+// from another.
+//
+// Only the names of the bound attributes are added to the salt of the
+// challenge (bind=ip,ua,session). The values are hashed into the signed data,
+// so they are never sent to the client.
+type Binding struct {
+
+	// IP is the IP address of the client, or the network it is in, such as
+	// 203.0.113.0/24. Binding to the network avoids rejecting clients which
+	// move between addresses in the same network.
+	IP string
+
+	// UserAgent is the User-Agent header of the client.
+	UserAgent string
+
+	// Session identifies the session of the client, such as a session ID.
+	Session string
+}
+
+// IsZero reports whether the binding has no attributes.
+func (binding Binding) IsZero() bool {
+	return binding == Binding{}
+}
+
+// names returns the names of the attributes which are set, separated by
+// commas, in the order they are hashed.
+func (binding Binding) names() string {
+	var names []string
+	if len(binding.IP) > 0 {
+		names = append(names, "ip")
+	}
+	if len(binding.UserAgent) > 0 {
+		names = append(names, "ua")
+	}
+	if len(binding.Session) > 0 {
+		names = append(names, "session")
+	}
+	return strings.Join(names, ",")
+}
+
+// value returns the value of the named attribute. Unknown names have no
+// value.
+func (binding Binding) value(name string) string {
+	switch name {
+	case "ip":
+		return binding.IP
+	case "ua":
+		return binding.UserAgent
+	case "session":
+		return binding.Session
+	}
+	return ""
+}
+
+// digest hashes the values of the named attributes. Each value is prefixed by
+// its length, so that there is no ambiguity about where each value ends.
+func (binding Binding) digest(names string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte("altcha binding\n"))
+	for _, name := range strings.Split(names, ",") {
+		value := binding.value(name)
+		hasher.Write([]byte(name + "=" + strconv.Itoa(len(value)) + ":" + value + "\n"))
+	}
+	return base64.RawURLEncoding.EncodeToString(hasher.Sum(nil))
+}
+
+// boundSigningInput returns the data which is signed for a challenge, which
+// includes the digest of the binding when the challenge is bound. Only the
+// attributes named in the salt are included, so that a challenge which is not
+// bound is signed in the same way as before.
+func boundSigningInput(message Message, binding Binding) string {
+	input := signingInput(message)
+	if names := message.SaltParams().Get("bind"); len(names) > 0 {
+		input += "\n" + binding.digest(names)
+	}
+	return input
+}
@@ -0,0 +1,73 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha/rand"
+	"strings"
+	"testing"
+)
+
+func TestBinding(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{})
+	binding := Binding{IP: "203.0.113.0/24", UserAgent: "Mozilla/5.0", Session: "session-id"}
+
+	msg := service.NewChallengeWithParams(Parameters{Binding: binding})
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	if got := msg.SaltParams().Get("bind"); got != "ip,ua,session" {
+		t.Errorf("Expected the bound attributes in the salt, got %q", got)
+	}
+	for _, value := range []string{binding.IP, binding.UserAgent, binding.Session} {
+		if strings.Contains(msg.Encode(), value) {
+			t.Errorf("Challenge exposes the bound value %q", value)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		binding Binding
+		wantErr bool
+	}{
+		{"Same", binding, false},
+		{"OtherIP", Binding{IP: "198.51.100.0/24", UserAgent: binding.UserAgent, Session: binding.Session}, true},
+		{"OtherUserAgent", Binding{IP: binding.IP, UserAgent: "curl/8.0", Session: binding.Session}, true},
+		{"OtherSession", Binding{IP: binding.IP, UserAgent: binding.UserAgent}, true},
+		{"None", Binding{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := service.VerifyMessage(msg, VerifyOptions{Binding: test.binding})
+			if !test.wantErr && err != nil {
+				t.Errorf("Expected response to be valid, got %v", err)
+			}
+			if test.wantErr && !(errors.Is(err, ErrBindingMismatch) && errors.Is(err, ErrBadSignature)) {
+				t.Errorf("Expected ErrBindingMismatch and ErrBadSignature, got %v", err)
+			}
+		})
+	}
+
+	// Challenges which are not bound are accepted with any binding
+	unbound := service.NewChallenge()
+	unbound.Number, _ = unbound.Solve(DefaultComplexity)
+	if err := service.VerifyMessage(unbound, VerifyOptions{Binding: binding}); err != nil {
+		t.Errorf("Expected unbound response to be valid, got %v", err)
+	}
+	if unbound.SaltParams().Has("bind") {
+		t.Errorf("Expected no bind parameter, got %q", unbound.Salt)
+	}
+
+	// Removing the bind parameter breaks the solution and the signature
+	stripped := msg
+	stripped.Salt = strings.Replace(msg.Salt, "bind=ip%2Cua%2Csession", "bind=", 1)
+	if err := service.VerifyMessage(stripped, VerifyOptions{}); err == nil {
+		t.Error("Expected response with a stripped binding to be rejected")
+	}
+}
@@ -6,15 +6,26 @@ package altcha
 
 // NewChallenge creates a new challenge with default parameters.
 func NewChallenge() (msg Message) {
-	return NewChallengeWithParams(Parameters{})
+	return defaultService.NewChallenge()
+}
+
+// NewChallenge creates a new challenge with default parameters.
+func (service *Service) NewChallenge() (msg Message) {
+	return service.NewChallengeWithParams(Parameters{})
 }
 
 // NewChallengeEncoded creates a new challenge with default parameters and
 // encoded for the client.
 func NewChallengeEncoded() string {
+	return defaultService.NewChallengeEncoded()
+}
+
+// NewChallengeEncoded creates a new challenge with default parameters and
+// encoded for the client.
+func (service *Service) NewChallengeEncoded() string {
 
 	// Create a new challenge message.
-	msg := NewChallengeWithParams(Parameters{})
+	msg := service.NewChallengeWithParams(Parameters{})
 
 	// Return the encoded challenge message.
 	return msg.Encode()
@@ -22,21 +33,28 @@ func NewChallengeEncoded() string {
 
 // NewChallengeWithParams creates a new challenge with the given parameters.
 func NewChallengeWithParams(params Parameters) (msg Message) {
+	return defaultService.NewChallengeWithParams(params)
+}
+
+// NewChallengeWithParams creates a new challenge with the given parameters.
+func (service *Service) NewChallengeWithParams(params Parameters) (msg Message) {
 
 	// Populate any missing parameters.
-	params.populate()
+	service.populate(&params)
+
+	// Add the ID of the signing key to the salt, so it can be found again.
+	id, signer := service.signer()
+	params.Salt = addSaltParam(params.Salt, "kid", id)
 
 	// Generate the challenge and signature.
 	algo, _ := AlgorithmFromString(params.Algorithm)
-	challenge := generateHash(algo, params.Salt, params.Number)
-	signature := Sign(algo, challenge)
 	msg = Message{
 		Algorithm: params.Algorithm,
 		Salt:      params.Salt,
-		Challenge: challenge,
-		Signature: signature,
+		Challenge: generateHash(algo, params.Salt, params.Number),
 		// Number is a secret and must not be exposed to the client.
 	}
+	msg.Signature = signer(algo, boundSigningInput(msg, params.Binding))
 
 	// Return the challenge message.
 	return msg
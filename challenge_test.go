@@ -21,7 +21,7 @@ func TestNewChallengeEncoded(t *testing.T) {
 	}
 	RotateSecrets() // Rotate secrets so that the fake random string is used
 
-	const want = `{"algorithm":"SHA-256","salt":"0V5xzYiSFmY1swbb","challenge":"69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7","signature":"-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k"}`
+	const want = `{"algorithm":"SHA-256","salt":"0V5xzYiSFmY1swbb?kid=e8r_5McqCvlF","challenge":"890664e0938f4fc7b8cbe3585e29c293bf28e32655cb10feb7e4ac86ebd808c2","signature":"8TqsLORz4J_Zv3xms7iG8M-rJc6dX48_PDtyuRbU8m4"}`
 
 	got := NewChallengeEncoded()
 
@@ -58,7 +58,7 @@ func TestNewChallengeWithParams(t *testing.T) {
 					Number:    34000,
 				},
 			},
-			want: `{"algorithm":"SHA-256","salt":"0V5xzYiSFmY1swbb","challenge":"7364dfc15e9cf0ab7d950dba7901144fcb88240e1b42f8581d3d1ddb41defe8a","signature":"P3UGNHXiFAg23SCvuHjZR4fOdRZAoH95JFIkA0Ph820"}`,
+			want: `{"algorithm":"SHA-256","salt":"0V5xzYiSFmY1swbb?kid=e8r_5McqCvlF","challenge":"09904ce396379d279453a929f42a87a27442bc7e44b8f62097ff6bff7f323046","signature":"qDQkfkKruedBd2cXSD_KMv7L3hmK6EvmjDU8i5iFeZs"}`,
 		},
 		{
 			name: "SHA-384-34000",
@@ -68,7 +68,7 @@ func TestNewChallengeWithParams(t *testing.T) {
 					Number:    34000,
 				},
 			},
-			want: `{"algorithm":"SHA-384","salt":"0V5xzYiSFmY1swbb","challenge":"c2d1fcad24fc054bed3352d4531fa6092912ef4abd1caa6962123fb81fd6a4670b04bf432551081f233c0b4164f15a34","signature":"y8ZwySQDrw0EO3QEbIF3yNubv0OXz-SEmJ2pcYBRRrGeSCXL9FYabf5iGQpPORLb"}`,
+			want: `{"algorithm":"SHA-384","salt":"0V5xzYiSFmY1swbb?kid=e8r_5McqCvlF","challenge":"49687e37e05861ca0804ed1712d2bb6b6d12e6231f0193ab635e76d4b77f666a11a3142c378c89414efb22205be8e5ad","signature":"g7jhhvVPwv7duWegem5Qw3YDeu4hz2bBl9rgeUsFAt7qBFVJp-ru11YACsaShDWB"}`,
 		},
 		{
 			name: "SHA-512-34000",
@@ -78,7 +78,7 @@ func TestNewChallengeWithParams(t *testing.T) {
 					Number:    34000,
 				},
 			},
-			want: `{"algorithm":"SHA-512","salt":"0V5xzYiSFmY1swbb","challenge":"46b8a27a0557814575bc70e78e4cf6515981c0b2012e3227745c09225cf096734fea3be283f4dac9f8d2f76c4af693f2d9217c3468e573b59279013a60fca64d","signature":"cNwX2ag-xox9brF5YPW8ISBkn2QXNig_Ut6f2xJfquL4Y-ey0gKXSPPH-4I6-mNqxPmZRzpevjC_1y8fAOiybA"}`,
+			want: `{"algorithm":"SHA-512","salt":"0V5xzYiSFmY1swbb?kid=e8r_5McqCvlF","challenge":"abd0b1a308b709d928fdd336f0a2ba426acfea57305170d0f498af9103f94d0fb4bc471d0de63d308b02c1b8c9640580e0cdfa4e74686c38840074c07cec2159","signature":"J97lWy3sM69ntxSnOyBcUsvMP9h1HJ14HZCUVSQ4Gwhe_FXwRuoML206OEtnoVdCDD_8URkedizo5lu996Phpw"}`,
 		},
 	}
 	for _, tt := range tests {
@@ -0,0 +1,69 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+// Package clock provides the time used by the altcha package, so that it can
+// be replaced in tests.
+package clock
+
+import "time"
+
+// Clock tells the time, and creates tickers and timers.
+//
+// Implementations must be concurrency safe.
+type Clock interface {
+
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a Ticker which ticks at the given interval, in the
+	// same way as time.NewTicker.
+	NewTicker(interval time.Duration) Ticker
+
+	// AfterFunc calls the function in its own goroutine once the duration has
+	// elapsed, in the same way as time.AfterFunc.
+	AfterFunc(duration time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks at intervals.
+type Ticker interface {
+
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks are delivered after Stop.
+	Stop()
+}
+
+// Timer is a pending call of a function.
+type Timer interface {
+
+	// Stop prevents the function from being called. It returns false if the
+	// function has already been called, or the timer was already stopped.
+	Stop() bool
+}
+
+// System is the Clock which uses the system time.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(interval time.Duration) Ticker {
+	return systemTicker{time.NewTicker(interval)}
+}
+
+func (systemClock) AfterFunc(duration time.Duration, f func()) Timer {
+	return time.AfterFunc(duration, f)
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (ticker systemTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
@@ -0,0 +1,166 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock for use in tests, where time only passes when it is
+// advanced. Tickers and timers fire as the time is advanced past them.
+//
+// Timer functions are called synchronously by Advance, so when Advance
+// returns, every timer which was due has run. Ticks are delivered in the
+// same way as a time.Ticker, so ticks are dropped when they are not read.
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock  *Fake
+	next   time.Time
+	period time.Duration  // only for tickers
+	ch     chan time.Time // only for tickers
+	f      func()         // only for timers
+}
+
+// NewFake creates a Fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the current time of the fake clock.
+func (fake *Fake) Now() time.Time {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.now
+}
+
+// NewTicker returns a Ticker which ticks each time the fake clock is
+// advanced past the interval.
+func (fake *Fake) NewTicker(interval time.Duration) Ticker {
+	if interval <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	waiter := &fakeWaiter{
+		clock:  fake,
+		next:   fake.now.Add(interval),
+		period: interval,
+		ch:     make(chan time.Time, 1),
+	}
+	fake.waiters = append(fake.waiters, waiter)
+	return fakeTicker{waiter}
+}
+
+// AfterFunc calls the function once the fake clock has been advanced by the
+// duration. When the duration is zero or less, the function is called in its
+// own goroutine straight away.
+func (fake *Fake) AfterFunc(duration time.Duration, f func()) Timer {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	waiter := &fakeWaiter{clock: fake, next: fake.now.Add(duration), f: f}
+	if duration <= 0 {
+		go f()
+		return waiter // already fired
+	}
+	fake.waiters = append(fake.waiters, waiter)
+	return waiter
+}
+
+// Advance moves the fake clock forward by the duration, firing any tickers and
+// timers which are due along the way.
+func (fake *Fake) Advance(duration time.Duration) {
+	fake.mutex.Lock()
+	target := fake.now.Add(duration)
+	fake.mutex.Unlock()
+	fake.Set(target)
+}
+
+// Set moves the fake clock to the given time, firing any tickers and timers
+// which are due along the way. Moving the clock backwards fires nothing.
+func (fake *Fake) Set(target time.Time) {
+	for {
+		fake.mutex.Lock()
+		waiter := fake.due(target)
+		if waiter == nil {
+			fake.now = target
+			fake.mutex.Unlock()
+			return
+		}
+		if waiter.next.After(fake.now) {
+			fake.now = waiter.next
+		}
+
+		if waiter.f != nil {
+			fake.remove(waiter)
+			fake.mutex.Unlock()
+			waiter.f() // outside the lock, so that it can use the clock
+			continue
+		}
+
+		// Skip any ticks which would be dropped anyway
+		if missed := target.Sub(waiter.next) / waiter.period; missed > 0 {
+			waiter.next = waiter.next.Add(missed * waiter.period)
+			fake.now = waiter.next
+		}
+		select {
+		case waiter.ch <- fake.now:
+		default: // dropped, as the previous tick has not been read
+		}
+		waiter.next = waiter.next.Add(waiter.period)
+		fake.mutex.Unlock()
+	}
+}
+
+// due returns the waiter which is due first, no later than the target.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (fake *Fake) due(target time.Time) (first *fakeWaiter) {
+	for _, waiter := range fake.waiters {
+		if waiter.next.After(target) {
+			continue
+		}
+		if first == nil || waiter.next.Before(first.next) {
+			first = waiter
+		}
+	}
+	return first
+}
+
+// remove removes the waiter, and reports whether it was present.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (fake *Fake) remove(waiter *fakeWaiter) bool {
+	for i, w := range fake.waiters {
+		if w == waiter {
+			fake.waiters = append(fake.waiters[:i], fake.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (ticker fakeTicker) C() <-chan time.Time {
+	return ticker.ch
+}
+
+func (ticker fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
+
+func (waiter *fakeWaiter) Stop() bool {
+	waiter.clock.mutex.Lock()
+	defer waiter.clock.mutex.Unlock()
+	return waiter.clock.remove(waiter)
+}
@@ -0,0 +1,81 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeNow(t *testing.T) {
+	fake := NewFake(epoch)
+	if !fake.Now().Equal(epoch) {
+		t.Errorf("Now() = %v, want %v", fake.Now(), epoch)
+	}
+	fake.Advance(time.Minute)
+	if want := epoch.Add(time.Minute); !fake.Now().Equal(want) {
+		t.Errorf("Now() = %v, want %v", fake.Now(), want)
+	}
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	fake := NewFake(epoch)
+
+	var fired []time.Time
+	fake.AfterFunc(time.Minute, func() {
+		fired = append(fired, fake.Now())
+		// Timers can be scheduled from within a timer
+		fake.AfterFunc(time.Minute, func() { fired = append(fired, fake.Now()) })
+	})
+	stopped := fake.AfterFunc(time.Minute, func() { t.Error("Expected stopped timer not to fire") })
+	if !stopped.Stop() {
+		t.Error("Expected Stop to report the timer was pending")
+	}
+
+	fake.Advance(59 * time.Second)
+	if len(fired) != 0 {
+		t.Fatalf("Expected timer not to fire early")
+	}
+
+	// Both timers fire, at the times they were due
+	fake.Advance(time.Hour)
+	if len(fired) != 2 || !fired[0].Equal(epoch.Add(time.Minute)) || !fired[1].Equal(epoch.Add(2*time.Minute)) {
+		t.Errorf("Timers fired at %v", fired)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	fake := NewFake(epoch)
+	ticker := fake.NewTicker(time.Second)
+
+	fake.Advance(time.Second)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(epoch.Add(time.Second)) {
+			t.Errorf("tick = %v", tick)
+		}
+	default:
+		t.Fatal("Expected a tick")
+	}
+
+	// Ticks which are not read are dropped, like a time.Ticker
+	fake.Advance(time.Hour)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Error("Expected only one tick to be buffered")
+	default:
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Error("Expected no ticks after Stop")
+	default:
+	}
+}
@@ -0,0 +1,123 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha/clock"
+	"github.com/k42-software/go-altcha/rand"
+	mathrand "math/rand"
+	"testing"
+	"time"
+)
+
+var fakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClockExpiry(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	fake := clock.NewFake(fakeEpoch)
+	service := NewService(Config{Clock: fake, ChallengeTTL: time.Minute, SecretsRotationInterval: -1})
+
+	msg := service.NewChallenge()
+	if expires, _ := msg.Expires(); !expires.Equal(fakeEpoch.Add(time.Minute)) {
+		t.Errorf("Expected expiry from the fake clock, got %v", expires)
+	}
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	if err := service.VerifyMessage(msg, VerifyOptions{}); err != nil {
+		t.Errorf("Expected response to be valid, got %v", err)
+	}
+	fake.Advance(time.Minute)
+	if err := service.VerifyMessage(msg, VerifyOptions{}); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired after advancing the clock, got %v", err)
+	}
+}
+
+func TestFakeClockEpochRotation(t *testing.T) {
+	fake := clock.NewFake(fakeEpoch)
+	masterKey := []byte("master key")
+	service := NewService(Config{Clock: fake, MasterKey: masterKey, SecretsRotationInterval: time.Hour})
+	defer service.Close()
+	service.Start()
+
+	epoch := SecretsEpoch(fakeEpoch, time.Hour)
+	if current, _ := service.GetSecrets(); current != DeriveSecret(masterKey, epoch) {
+		t.Error("Expected the secret for the current epoch")
+	}
+
+	// The rotation runs as the clock passes the end of the epoch
+	fake.Advance(time.Hour)
+	service.secretsMutex.RLock()
+	rotatedEpoch := service.secretsEpoch
+	service.secretsMutex.RUnlock()
+	if rotatedEpoch != epoch+1 {
+		t.Errorf("Expected the secrets to be rotated to epoch %d, got %d", epoch+1, rotatedEpoch)
+	}
+	if current, previous := service.GetSecrets(); current != DeriveSecret(masterKey, epoch+1) || previous != DeriveSecret(masterKey, epoch) {
+		t.Error("Expected the secrets for the next epoch")
+	}
+}
+
+func TestFakeClockTickerRotation(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	fake := clock.NewFake(fakeEpoch)
+	service := NewService(Config{Clock: fake, SecretsRotationInterval: time.Minute})
+	defer service.Close()
+
+	rotated := make(chan struct{}, 10)
+	service.Start()
+	service.AddSecretsRotationCallback(func() { rotated <- struct{}{} })
+	before, _ := service.GetSecrets()
+
+	fake.Advance(time.Minute)
+	select {
+	case <-rotated:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the secrets to be rotated when the clock is advanced")
+	}
+	if after, _ := service.GetSecrets(); after == before {
+		t.Error("Expected the current secret to change")
+	}
+}
+
+func TestFakeClockReplayEviction(t *testing.T) {
+	fake := clock.NewFake(fakeEpoch)
+	store := NewMemoryReplayStore(0)
+	store.SetClock(fake)
+
+	store.Ban("signature", time.Minute)
+	if !store.IsBanned("signature") {
+		t.Fatal("Expected signature to be banned")
+	}
+	fake.Advance(time.Minute)
+	if store.IsBanned("signature") {
+		t.Error("Expected the ban to expire when the clock is advanced")
+	}
+	if store.Ban("signature", time.Minute) {
+		t.Error("Expected an expired signature to be banned again")
+	}
+}
+
+func TestDeterministicRand(t *testing.T) {
+	newService := func() *Service {
+		return NewService(Config{
+			Clock:                   clock.NewFake(fakeEpoch),
+			Rand:                    mathrand.New(mathrand.NewSource(1)),
+			ChallengeTTL:            time.Minute,
+			SecretsRotationInterval: -1,
+		})
+	}
+
+	first, second := newService(), newService()
+	if a, b := first.NewChallengeEncoded(), second.NewChallengeEncoded(); a != b {
+		t.Errorf("Expected the same challenge from the same entropy, got %s and %s", a, b)
+	}
+}
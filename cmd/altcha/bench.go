@@ -0,0 +1,66 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runBench measures how quickly challenges can be solved on this machine, for
+// each of the algorithms.
+func runBench(env *environment, args []string) error {
+	var (
+		hashes     int
+		workers    int
+		complexity int
+	)
+	flags := flag.NewFlagSet("altcha bench", flag.ContinueOnError)
+	flags.IntVar(&hashes, "n", 1000000, "the number of hashes to compute for each algorithm")
+	flags.IntVar(&workers, "workers", 0, "the number of goroutines used (default the number of CPUs)")
+	flags.IntVar(&complexity, "complexity", altcha.DefaultComplexity, "the complexity used to estimate the time to solve a challenge")
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "ALGORITHM\tHASHES/SEC\tAVERAGE SOLVE\tWORST SOLVE")
+
+	for _, algo := range []altcha.Algorithm{altcha.SHA256, altcha.SHA384, altcha.SHA512} {
+
+		// A challenge with no solution, so that every number is tried
+		unsolvable := altcha.Message{
+			Algorithm: algo.String(),
+			Salt:      "altcha-bench-salt",
+			Challenge: strings.Repeat("0", 64),
+		}
+
+		start := time.Now()
+		_, err := unsolvable.SolveContext(context.Background(), altcha.SolveOptions{
+			MaximumComplexity: hashes,
+			Workers:           workers,
+		})
+		elapsed := time.Since(start)
+		if !errors.Is(err, altcha.ErrNoSolution) {
+			return err
+		}
+
+		rate := float64(hashes) / elapsed.Seconds()
+		perHash := time.Duration(float64(time.Second) / rate)
+		_, _ = fmt.Fprintf(table, "%s\t%.0f\t%v\t%v\n", algo,
+			rate,
+			(perHash * time.Duration(complexity/2)).Round(time.Microsecond),
+			(perHash * time.Duration(complexity)).Round(time.Microsecond),
+		)
+	}
+
+	return table.Flush()
+}
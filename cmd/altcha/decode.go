@@ -0,0 +1,60 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var formatNames = map[format]string{
+	formatJSON:   "JSON",
+	formatBase64: "base64 wrapped JSON",
+	formatText:   "M2M text",
+}
+
+// runDecode decodes a challenge or response in any of the formats, and pretty
+// prints it, along with the parameters in the salt.
+func runDecode(env *environment, args []string) error {
+	flags := flag.NewFlagSet("altcha decode", flag.ContinueOnError)
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+
+	input, err := readInput(env, flags.Args())
+	if err != nil {
+		return err
+	}
+	msg, encoding, err := decodeMessage(input)
+	if err != nil {
+		return err
+	}
+
+	pretty, _ := json.MarshalIndent(msg, "", "  ")
+	_, _ = fmt.Fprintln(env.stdout, string(pretty))
+	_, _ = fmt.Fprintf(env.stdout, "format: %s\n", formatNames[encoding])
+
+	params := msg.SaltParams()
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(env.stdout, "salt parameter %s: %s\n", key, params.Get(key))
+	}
+
+	if expires, ok := msg.Expires(); ok {
+		state := "expires in " + time.Until(expires).Round(time.Second).String()
+		if msg.IsExpired() {
+			state = "expired " + time.Since(expires).Round(time.Second).String() + " ago"
+		}
+		_, _ = fmt.Fprintf(env.stdout, "expires: %s (%s)\n", expires.Format(time.RFC3339), state)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// runGenerate generates a new challenge, signed using the given secret.
+func runGenerate(env *environment, args []string) error {
+	var (
+		secrets    secretFlags
+		algorithm  string
+		complexity int
+		ttl        time.Duration
+		text       bool
+	)
+	flags := flag.NewFlagSet("altcha generate", flag.ContinueOnError)
+	secrets.register(flags)
+	flags.StringVar(&algorithm, "algorithm", "SHA-256", "the hashing `algorithm`: SHA-256, SHA-384 or SHA-512")
+	flags.IntVar(&complexity, "complexity", altcha.DefaultComplexity, "the maximum secret number")
+	flags.DurationVar(&ttl, "ttl", 0, "how long the challenge is valid for (default until the secret is rotated)")
+	flags.BoolVar(&text, "text", false, "output the M2M text format, instead of JSON")
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New("too many arguments")
+	}
+
+	if _, ok := altcha.AlgorithmFromString(algorithm); !ok {
+		return errors.Errorf("unsupported algorithm %s", algorithm)
+	}
+
+	config, err := secrets.config()
+	if err != nil {
+		return err
+	}
+	if !secrets.configured() {
+		_, _ = fmt.Fprintln(env.stderr, "altcha generate: no secret given, so the challenge is signed with a random secret and cannot be verified")
+	}
+	service := newService(config)
+	defer service.Close()
+
+	params := altcha.Parameters{Algorithm: algorithm, Complexity: complexity}
+	if ttl > 0 {
+		params.Expires = time.Now().Add(ttl)
+	}
+	msg := service.NewChallengeWithParams(params)
+
+	if text {
+		_, _ = fmt.Fprintln(env.stdout, msg.String())
+	} else {
+		_, _ = fmt.Fprintln(env.stdout, msg.Encode())
+	}
+	return nil
+}
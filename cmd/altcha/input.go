@@ -0,0 +1,146 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// format is how a message is encoded.
+type format int
+
+const (
+	formatJSON   format = iota // raw JSON, as sent to the widget
+	formatBase64               // base64 wrapped JSON, as sent by the widget
+	formatText                 // M2M text format, as sent in headers
+)
+
+// parseFlags parses the flags, and wraps any error as a usageError.
+func parseFlags(flags *flag.FlagSet, env *environment, args []string) error {
+	flags.SetOutput(env.stderr)
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	return nil
+}
+
+// readInput returns the first argument, or reads stdin when there are no
+// arguments, with any surrounding whitespace removed.
+func readInput(env *environment, args []string) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("too many arguments")
+	}
+	if len(args) == 1 {
+		return strings.TrimSpace(args[0]), nil
+	}
+	input, err := io.ReadAll(env.stdin)
+	if err != nil {
+		return "", errors.Wrap(err, "reading stdin")
+	}
+	return strings.TrimSpace(string(input)), nil
+}
+
+// decodeMessage decodes a message in any of the formats.
+func decodeMessage(input string) (msg altcha.Message, encoding format, err error) {
+	switch {
+	case len(input) == 0:
+		return msg, encoding, errors.New("no input")
+	case strings.HasPrefix(input, altcha.TextPrefix):
+		msg, err = altcha.DecodeText(input)
+		return msg, formatText, err
+	case strings.HasPrefix(input, "{"):
+		msg, err = altcha.DecodeJSON([]byte(input))
+		return msg, formatJSON, err
+	default:
+		msg, err = altcha.DecodeResponse(input)
+		return msg, formatBase64, err
+	}
+}
+
+// encodeMessage encodes the message in the given format.
+func encodeMessage(msg altcha.Message, encoding format) string {
+	switch encoding {
+	case formatText:
+		return msg.String()
+	case formatBase64:
+		return msg.EncodeWithBase64()
+	default:
+		return msg.Encode()
+	}
+}
+
+// secretFlags are the flags which configure the keys of the service.
+type secretFlags struct {
+	secret           string
+	secretFile       string
+	secretEnv        string
+	masterKey        string
+	rotationInterval time.Duration
+	publicKey        string
+	legacy           bool
+}
+
+func (secrets *secretFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&secrets.secret, "secret", "", "the secret used for the hmac")
+	flags.StringVar(&secrets.secretFile, "secret-file", "", "read the secret used for the hmac from a `file`")
+	flags.StringVar(&secrets.secretEnv, "secret-env", "", "read the secret used for the hmac from an environment `variable`")
+	flags.StringVar(&secrets.masterKey, "master-key", "", "derive the secrets from a master key")
+	flags.DurationVar(&secrets.rotationInterval, "rotation-interval", 0, "the secrets rotation interval used with -master-key (default 5m)")
+	flags.StringVar(&secrets.publicKey, "public-key", "", "an Ed25519 public key, base64url encoded, for verifying")
+	flags.BoolVar(&secrets.legacy, "legacy", false, "accept legacy signatures over the challenge hash only")
+}
+
+// configured reports whether any keys were given.
+func (secrets *secretFlags) configured() bool {
+	return len(secrets.secret) > 0 || len(secrets.secretFile) > 0 || len(secrets.secretEnv) > 0 ||
+		len(secrets.masterKey) > 0 || len(secrets.publicKey) > 0
+}
+
+// config returns the service configuration for the flags.
+func (secrets *secretFlags) config() (config altcha.Config, err error) {
+	config.AcceptLegacySignatures = secrets.legacy
+	config.SecretsRotationInterval = secrets.rotationInterval
+
+	switch {
+	case len(secrets.secret) > 0:
+		config.Secrets = altcha.StaticSecret(secrets.secret)
+	case len(secrets.secretFile) > 0:
+		if config.Secrets, err = altcha.NewFileSecrets(secrets.secretFile); err != nil {
+			return config, err
+		}
+	case len(secrets.secretEnv) > 0:
+		if config.Secrets, err = altcha.EnvSecrets(secrets.secretEnv); err != nil {
+			return config, err
+		}
+	case len(secrets.masterKey) > 0:
+		config.MasterKey = []byte(secrets.masterKey)
+	}
+
+	if len(secrets.publicKey) > 0 {
+		key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(secrets.publicKey, "="))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return config, errors.New("invalid Ed25519 public key")
+		}
+		config.VerificationKeys = []ed25519.PublicKey{key}
+	}
+
+	return config, nil
+}
+
+// newService creates a service for a single command. Random secrets are not
+// rotated, as the command does not run for long enough.
+func newService(config altcha.Config) *altcha.Service {
+	if len(config.MasterKey) == 0 {
+		config.SecretsRotationInterval = -1
+	}
+	return altcha.NewService(config)
+}
@@ -0,0 +1,104 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+// Command altcha is a tool for generating, solving, verifying and decoding
+// altcha challenges, which is useful when debugging rejected responses.
+//
+// Usage:
+//
+//	altcha <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	generate  generate a new challenge
+//	solve     solve a challenge read from stdin
+//	verify    verify a response against a secret
+//	decode    decode and pretty print a challenge or response
+//	bench     measure the local hash rate of each algorithm
+//	serve     run a verification server for other applications
+//
+// Run "altcha <command> -h" for the flags of each command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a subcommand of the tool.
+type command struct {
+	name        string
+	description string
+	run         func(env *environment, args []string) error
+}
+
+// environment holds the input and output streams, so that the commands can be
+// tested.
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = []command{
+	{"generate", "generate a new challenge", runGenerate},
+	{"solve", "solve a challenge read from stdin", runSolve},
+	{"verify", "verify a response against a secret", runVerify},
+	{"decode", "decode and pretty print a challenge or response", runDecode},
+	{"bench", "measure the local hash rate of each algorithm", runBench},
+	{"serve", "run a verification server for other applications", runServe},
+}
+
+func main() {
+	os.Exit(run(&environment{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:]))
+}
+
+// run runs the command given by the arguments, and returns the exit code.
+func run(env *environment, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(env.stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(env, args[1:])
+		switch err.(type) {
+		case nil:
+			return 0
+		case usageError:
+			return 2
+		case verifyError:
+			_, _ = fmt.Fprintln(env.stdout, err)
+			return 1
+		default:
+			_, _ = fmt.Fprintf(env.stderr, "altcha %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	_, _ = fmt.Fprintf(env.stderr, "altcha: unknown command %q\n", args[0])
+	usage(env.stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: altcha <command> [flags] [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "The commands are:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.description)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, `Run "altcha <command> -h" for the flags of each command.`)
+}
+
+// usageError is returned when the flags could not be parsed. The flag package
+// has already reported the problem.
+type usageError struct {
+	error
+}
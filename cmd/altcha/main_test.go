@@ -0,0 +1,126 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"bytes"
+	"github.com/k42-software/go-altcha"
+	"strings"
+	"testing"
+)
+
+// runCommand runs the tool with the given stdin and arguments.
+func runCommand(stdin string, args ...string) (stdout, stderr string, code int) {
+	var out, errOut bytes.Buffer
+	code = run(&environment{strings.NewReader(stdin), &out, &errOut}, args)
+	return out.String(), errOut.String(), code
+}
+
+func TestGenerateSolveVerify(t *testing.T) {
+	for _, format := range []string{"-text=false", "-text=true"} {
+		challenge, stderr, code := runCommand("", "generate", "-secret", "s3cret", "-complexity", "5000", "-ttl", "1m", format)
+		if code != 0 {
+			t.Fatalf("generate %s: exit code %d: %s", format, code, stderr)
+		}
+
+		response, stderr, code := runCommand(challenge, "solve")
+		if code != 0 {
+			t.Fatalf("solve %s: exit code %d: %s", format, code, stderr)
+		}
+		if !strings.Contains(stderr, "solved: number=") {
+			t.Errorf("solve %s: expected the number and elapsed time to be reported, got %q", format, stderr)
+		}
+
+		stdout, _, code := runCommand(response, "verify", "-secret", "s3cret")
+		if code != 0 || strings.TrimSpace(stdout) != "valid" {
+			t.Errorf("verify %s: expected valid, got %q with exit code %d", format, stdout, code)
+		}
+
+		stdout, _, code = runCommand(response, "verify", "-secret", "wrong")
+		if code != 1 || !strings.Contains(stdout, "signature does not match") {
+			t.Errorf("verify %s: expected the signature to be rejected, got %q with exit code %d", format, stdout, code)
+		}
+	}
+}
+
+func TestVerifyReasons(t *testing.T) {
+	stdout, _, code := runCommand("not a response", "verify", "-secret", "s3cret")
+	if code != 1 || !strings.Contains(stdout, "malformed") {
+		t.Errorf("expected a malformed response to be explained, got %q with exit code %d", stdout, code)
+	}
+
+	_, stderr, code := runCommand("", "verify")
+	if code != 1 || !strings.Contains(stderr, "required") {
+		t.Errorf("expected verify without a secret to fail, got %q with exit code %d", stderr, code)
+	}
+}
+
+func TestVerifyScopeAndBinding(t *testing.T) {
+	service := newService(altcha.Config{Secrets: altcha.StaticSecret("s3cret")})
+	defer service.Close()
+	msg := service.NewChallengeWithParams(altcha.Parameters{
+		Complexity: 5000,
+		Scope:      "signup",
+		Binding:    altcha.Binding{IP: "203.0.113.0/24", Session: "abc"},
+	})
+	msg.Number, _ = msg.Solve(5000)
+	response := msg.EncodeWithBase64()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"Valid", []string{"-scope", "signup", "-bind-ip", "203.0.113.0/24", "-bind-session", "abc"}, "valid"},
+		{"WrongScope", []string{"-scope", "login", "-bind-ip", "203.0.113.0/24", "-bind-session", "abc"}, "invalid: the challenge was issued for a different scope"},
+		{"WrongBinding", []string{"-scope", "signup", "-bind-ip", "198.51.100.0/24", "-bind-session", "abc"}, "invalid: the challenge was bound"},
+		{"NoBinding", []string{"-scope", "signup"}, "invalid: the challenge was bound"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, _ := runCommand(response, append([]string{"verify", "-secret", "s3cret"}, tc.args...)...)
+			if !strings.HasPrefix(stdout, tc.want) {
+				t.Errorf("expected %q, got %q %q", tc.want, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	challenge, _, _ := runCommand("", "generate", "-secret", "s3cret", "-ttl", "1m")
+	stdout, stderr, code := runCommand("", "decode", challenge)
+	if code != 0 {
+		t.Fatalf("decode: exit code %d: %s", code, stderr)
+	}
+	for _, want := range []string{`"algorithm": "SHA-256"`, "format: JSON", "salt parameter kid:", "expires in"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected decode output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	stdout, stderr, code := runCommand("", "bench", "-n", "2048")
+	if code != 0 {
+		t.Fatalf("bench: exit code %d: %s", code, stderr)
+	}
+	for _, algo := range []string{"SHA-256", "SHA-384", "SHA-512"} {
+		if !strings.Contains(stdout, algo) {
+			t.Errorf("expected bench output to contain %s, got:\n%s", algo, stdout)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if _, stderr, code := runCommand(""); code != 2 || !strings.Contains(stderr, "Usage") {
+		t.Errorf("expected usage without a command, got %q with exit code %d", stderr, code)
+	}
+	if _, _, code := runCommand("", "unknown"); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown command, got %d", code)
+	}
+	if _, _, code := runCommand("", "generate", "-unknown"); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown flag, got %d", code)
+	}
+}
@@ -0,0 +1,236 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/k42-software/go-altcha"
+	altchahttp "github.com/k42-software/go-altcha/http"
+	"github.com/pkg/errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// serverConfig is the configuration of the verification server. It is read
+// from a JSON file, and then from the environment, which takes precedence.
+type serverConfig struct {
+	Listen           string   `json:"listen"`            // ALTCHA_LISTEN
+	Secret           string   `json:"secret"`            // ALTCHA_SECRET
+	SecretFile       string   `json:"secret_file"`       // ALTCHA_SECRET_FILE
+	MasterKey        string   `json:"master_key"`        // ALTCHA_MASTER_KEY
+	RotationInterval duration `json:"rotation_interval"` // ALTCHA_ROTATION_INTERVAL
+	Algorithm        string   `json:"algorithm"`         // ALTCHA_ALGORITHM
+	Complexity       int      `json:"complexity"`        // ALTCHA_COMPLEXITY
+	ChallengeTTL     duration `json:"challenge_ttl"`     // ALTCHA_CHALLENGE_TTL
+	ReplayStoreSize  int      `json:"replay_store_size"` // ALTCHA_REPLAY_STORE_SIZE
+	TrustedProxies   []string `json:"trusted_proxies"`   // ALTCHA_TRUSTED_PROXIES, comma separated
+	ForwardedHeader  string   `json:"forwarded_header"`  // ALTCHA_FORWARDED_HEADER
+}
+
+// duration is a time.Duration which is written as a string in JSON, such as
+// "5m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.Wrap(err, "durations must be strings, such as \"5m\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	*d = duration(parsed)
+	return err
+}
+
+// defaultListen only accepts connections from the local machine, as the
+// server is intended to run alongside the applications which use it.
+const defaultListen = "127.0.0.1:3004"
+
+// loadServerConfig reads the configuration from the file, when given, and then
+// from the environment.
+func loadServerConfig(path string, getenv func(string) string) (config serverConfig, err error) {
+	config.Listen = defaultListen
+
+	if len(path) > 0 {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return config, errors.Wrap(err, "reading config file")
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return config, errors.Wrap(err, "parsing config file")
+		}
+	}
+
+	stringVars := map[string]*string{
+		"ALTCHA_LISTEN":           &config.Listen,
+		"ALTCHA_SECRET":           &config.Secret,
+		"ALTCHA_SECRET_FILE":      &config.SecretFile,
+		"ALTCHA_MASTER_KEY":       &config.MasterKey,
+		"ALTCHA_ALGORITHM":        &config.Algorithm,
+		"ALTCHA_FORWARDED_HEADER": &config.ForwardedHeader,
+	}
+	for name, target := range stringVars {
+		if value := getenv(name); len(value) > 0 {
+			*target = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"ALTCHA_COMPLEXITY":        &config.Complexity,
+		"ALTCHA_REPLAY_STORE_SIZE": &config.ReplayStoreSize,
+	}
+	for name, target := range intVars {
+		if value := getenv(name); len(value) > 0 {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return config, errors.Wrapf(err, "parsing %s", name)
+			}
+		}
+	}
+
+	durationVars := map[string]*duration{
+		"ALTCHA_ROTATION_INTERVAL": &config.RotationInterval,
+		"ALTCHA_CHALLENGE_TTL":     &config.ChallengeTTL,
+	}
+	for name, target := range durationVars {
+		if value := getenv(name); len(value) > 0 {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return config, errors.Wrapf(err, "parsing %s", name)
+			}
+			*target = duration(parsed)
+		}
+	}
+
+	if value := getenv("ALTCHA_TRUSTED_PROXIES"); len(value) > 0 {
+		config.TrustedProxies = strings.Split(value, ",")
+	}
+
+	return config, nil
+}
+
+// serviceConfig returns the configuration of the altcha service.
+func (config serverConfig) serviceConfig() (serviceConfig altcha.Config, err error) {
+	serviceConfig = altcha.Config{
+		Algorithm:               config.Algorithm,
+		Complexity:              config.Complexity,
+		SecretsRotationInterval: time.Duration(config.RotationInterval),
+		ChallengeTTL:            time.Duration(config.ChallengeTTL),
+		ReplayStore:             altcha.NewMemoryReplayStore(config.ReplayStoreSize),
+	}
+	if _, ok := altcha.AlgorithmFromString(config.Algorithm); len(config.Algorithm) > 0 && !ok {
+		return serviceConfig, errors.Errorf("unsupported algorithm %s", config.Algorithm)
+	}
+
+	switch {
+	case len(config.Secret) > 0:
+		serviceConfig.Secrets = altcha.StaticSecret(config.Secret)
+	case len(config.SecretFile) > 0:
+		if serviceConfig.Secrets, err = altcha.NewFileSecrets(config.SecretFile); err != nil {
+			return serviceConfig, err
+		}
+	case len(config.MasterKey) > 0:
+		serviceConfig.MasterKey = []byte(config.MasterKey)
+	}
+
+	return serviceConfig, nil
+}
+
+// newServerHandler returns the handler for the verification server.
+func newServerHandler(protector *altchahttp.Protector) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/challenge", protector.ServeChallenge)
+	mux.HandleFunc("/verify", protector.ServeVerify)
+	mux.HandleFunc("/auth", protector.ForwardAuth)
+	mux.HandleFunc("/altcha.js", altchahttp.ServeJavascript)
+	mux.HandleFunc("/altcha.js.license", altchahttp.ServeJavascript)
+	mux.HandleFunc("/altcha.min.js", altchahttp.ServeJavascript)
+	mux.HandleFunc("/altcha.min.js.license", altchahttp.ServeJavascript)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// runServe runs the verification server, until it receives an interrupt or
+// terminate signal.
+func runServe(env *environment, args []string) error {
+	var configPath, listen string
+	flags := flag.NewFlagSet("altcha serve", flag.ContinueOnError)
+	flags.StringVar(&configPath, "config", "", "read the configuration from a JSON `file`")
+	flags.StringVar(&listen, "listen", "", "the `address` to listen on (default "+defaultListen+")")
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New("too many arguments")
+	}
+
+	config, err := loadServerConfig(configPath, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if len(listen) > 0 {
+		config.Listen = listen
+	}
+	serviceConfig, err := config.serviceConfig()
+	if err != nil {
+		return err
+	}
+
+	trustedProxies, err := altchahttp.ParseTrustedProxies(config.TrustedProxies...)
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(env.stderr, "", log.LstdFlags)
+	if serviceConfig.Secrets == nil && serviceConfig.MasterKey == nil {
+		logger.Println("altcha serve: no secret configured, so challenges can only be verified by this server")
+	}
+
+	service := altcha.NewService(serviceConfig)
+	defer service.Close()
+	service.Start()
+
+	server := &http.Server{
+		Addr: config.Listen,
+		Handler: newServerHandler(&altchahttp.Protector{
+			Service:         service,
+			ErrorLog:        logger,
+			TrustedProxies:  trustedProxies,
+			ForwardedHeader: config.ForwardedHeader,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          logger,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Printf("altcha serve: listening on http://%s/", config.Listen)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return errors.Wrap(err, "shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/k42-software/go-altcha"
+	altchahttp "github.com/k42-software/go-altcha/http"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadServerConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"listen": ":8080", "secret": "from-file", "complexity": 5000, "challenge_ttl": "2m"}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"ALTCHA_SECRET":            "from-env",
+		"ALTCHA_ROTATION_INTERVAL": "1h",
+		"ALTCHA_TRUSTED_PROXIES":   "10.0.0.0/8,192.0.2.1",
+		"ALTCHA_FORWARDED_HEADER":  "X-Real-IP",
+	}
+	config, err := loadServerConfig(path, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Listen != ":8080" || config.Complexity != 5000 || time.Duration(config.ChallengeTTL) != 2*time.Minute {
+		t.Errorf("Expected values from the file, got %+v", config)
+	}
+	if config.Secret != "from-env" || time.Duration(config.RotationInterval) != time.Hour {
+		t.Errorf("Expected the environment to take precedence, got %+v", config)
+	}
+	if len(config.TrustedProxies) != 2 || config.TrustedProxies[1] != "192.0.2.1" {
+		t.Errorf("Expected the trusted proxies from the environment, got %v", config.TrustedProxies)
+	}
+	if config.ForwardedHeader != "X-Real-IP" {
+		t.Errorf("Expected the forwarded header from the environment, got %q", config.ForwardedHeader)
+	}
+
+	if _, err := loadServerConfig("", func(string) string { return "" }); err != nil {
+		t.Errorf("Expected the defaults without a file, got %v", err)
+	}
+	if _, err := loadServerConfig("", func(name string) string { return map[string]string{"ALTCHA_COMPLEXITY": "lots"}[name] }); err == nil {
+		t.Error("Expected an error for an invalid number")
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	config := serverConfig{Secret: "s3cret", Complexity: 5000}
+	serviceConfig, err := config.serviceConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := altcha.NewService(serviceConfig)
+	defer service.Close()
+
+	server := httptest.NewServer(newServerHandler(&altchahttp.Protector{Service: service}))
+	defer server.Close()
+
+	// Fetch and solve a challenge
+	resp, err := http.Get(server.URL + "/challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	response, ok := altcha.SolveChallenge(string(challenge), 0)
+	if !ok {
+		t.Fatalf("could not solve challenge %s", challenge)
+	}
+
+	verify := func() (int, altchahttp.Verdict) {
+		resp, err := http.PostForm(server.URL+"/verify", url.Values{"altcha": {response}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var verdict altchahttp.Verdict
+		if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, verdict
+	}
+
+	if status, verdict := verify(); status != http.StatusOK || !verdict.Valid {
+		t.Errorf("Expected the response to be valid, got %v %+v", status, verdict)
+	}
+	if status, verdict := verify(); status != http.StatusForbidden || verdict.Reason != "replay" {
+		t.Errorf("Expected the replay to be rejected, got %v %+v", status, verdict)
+	}
+
+	for _, path := range []string{"/altcha.min.js", "/healthz"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			t.Errorf("%s: expected success, got %v", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// runSolve solves a challenge, and outputs the response in the encoding which
+// the server expects. Challenges in JSON are answered with base64 wrapped
+// JSON, as sent by the widget, and challenges in the M2M text format are
+// answered in the same format.
+func runSolve(env *environment, args []string) error {
+	var (
+		maximum int
+		timeout time.Duration
+		workers int
+	)
+	flags := flag.NewFlagSet("altcha solve", flag.ContinueOnError)
+	flags.IntVar(&maximum, "max", 0, "the largest number tried (default 200000)")
+	flags.DurationVar(&timeout, "timeout", 0, "the longest time spent solving (default no limit)")
+	flags.IntVar(&workers, "workers", 0, "the number of goroutines used (default the number of CPUs)")
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+
+	input, err := readInput(env, flags.Args())
+	if err != nil {
+		return err
+	}
+	msg, encoding, err := decodeMessage(input)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	solution, err := msg.SolveContext(ctx, altcha.SolveOptions{MaximumComplexity: maximum, Workers: workers})
+	if err != nil {
+		return errors.Wrap(err, "solving challenge")
+	}
+	msg.Number = solution.Number
+
+	if encoding == formatJSON {
+		encoding = formatBase64
+	}
+	_, _ = fmt.Fprintln(env.stdout, encodeMessage(msg, encoding))
+	_, _ = fmt.Fprintf(env.stderr, "solved: number=%d in %v\n", solution.Number, solution.Elapsed.Round(time.Microsecond))
+	return nil
+}
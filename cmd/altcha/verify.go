@@ -0,0 +1,90 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// verifyError is returned when the response is not valid. It is reported on
+// stdout, as it is the result of the command rather than a failure of it.
+type verifyError struct {
+	error
+}
+
+// reasons explains each of the errors returned when verifying.
+var reasons = []struct {
+	err    error
+	reason string
+}{
+	{altcha.ErrMalformed, "the response is malformed or missing required values"},
+	{altcha.ErrUnsupportedAlgorithm, "the hashing algorithm is not supported"},
+	{altcha.ErrBadSolution, "the number is not the solution to the challenge"},
+	{altcha.ErrBindingMismatch, "the challenge was bound to a client, and the signature does not match this one"},
+	{altcha.ErrBadSignature, "the signature does not match; the challenge was tampered with, or signed with a different or rotated out secret"},
+	{altcha.ErrExpired, "the challenge has expired"},
+	{altcha.ErrWrongScope, "the challenge was issued for a different scope, or without a scope"},
+}
+
+// explain returns a human readable explanation of why the response is invalid.
+func explain(err error) string {
+	for _, reason := range reasons {
+		if errors.Is(err, reason.err) {
+			return reason.reason
+		}
+	}
+	return err.Error()
+}
+
+// runVerify verifies a response using the given secret, and explains why it
+// is invalid. Replays are not checked, as the command has no replay store.
+func runVerify(env *environment, args []string) error {
+	var (
+		secrets secretFlags
+		options altcha.VerifyOptions
+	)
+	flags := flag.NewFlagSet("altcha verify", flag.ContinueOnError)
+	secrets.register(flags)
+	flags.StringVar(&options.Scope, "scope", "", "the `scope` the response must have been issued for")
+	flags.StringVar(&options.Binding.IP, "bind-ip", "", "the IP `address` or network the challenge was bound to, such as 203.0.113.0/24")
+	flags.StringVar(&options.Binding.UserAgent, "bind-ua", "", "the User-Agent `header` the challenge was bound to")
+	flags.StringVar(&options.Binding.Session, "bind-session", "", "the session `id` the challenge was bound to")
+	if err := parseFlags(flags, env, args); err != nil {
+		return err
+	}
+	if !secrets.configured() {
+		return errors.New("a secret, master key or public key is required")
+	}
+
+	config, err := secrets.config()
+	if err != nil {
+		return err
+	}
+	service := newService(config)
+	defer service.Close()
+
+	input, err := readInput(env, flags.Args())
+	if err != nil {
+		return err
+	}
+	msg, _, err := decodeMessage(input)
+	if err != nil {
+		return verifyError{errors.Errorf("invalid: %s (%v)", explain(altcha.ErrMalformed), err)}
+	}
+
+	if err := service.VerifyMessage(msg, options); err != nil {
+		if expires, ok := msg.Expires(); ok && errors.Is(err, altcha.ErrExpired) {
+			return verifyError{errors.Errorf("invalid: %s at %s", explain(err), expires.Format(time.RFC3339))}
+		}
+		return verifyError{errors.Errorf("invalid: %s", explain(err))}
+	}
+
+	_, _ = fmt.Fprintln(env.stdout, "valid")
+	return nil
+}
@@ -0,0 +1,49 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// deriveSalt is the HKDF salt used when deriving secrets from a master key.
+const deriveSalt = "github.com/k42-software/go-altcha"
+
+// DeriveSecret derives the secret for the given epoch from the master key,
+// using HKDF with SHA-256 (RFC 5869). Every instance configured with the same
+// master key derives the same secret for the same epoch.
+func DeriveSecret(masterKey []byte, epoch int64) string {
+
+	// HKDF-Extract
+	extractor := hmac.New(sha256.New, []byte(deriveSalt))
+	extractor.Write(masterKey)
+	pseudoRandomKey := extractor.Sum(nil)
+
+	// HKDF-Expand, for a single block, which is all the output we need
+	expander := hmac.New(sha256.New, pseudoRandomKey)
+	expander.Write([]byte("altcha secret epoch " + strconv.FormatInt(epoch, 10)))
+	expander.Write([]byte{1})
+
+	return base64.RawURLEncoding.EncodeToString(expander.Sum(nil))
+}
+
+// SecretsEpoch returns the epoch containing the given time, for epochs of the
+// given length, counted from the unix epoch. When the length is zero or less,
+// there is only a single epoch, which is zero.
+func SecretsEpoch(at time.Time, length time.Duration) int64 {
+	if length <= 0 {
+		return 0
+	}
+	return at.UnixNano() / int64(length)
+}
+
+// secretsEpochStart returns the time at which the given epoch starts.
+func secretsEpochStart(epoch int64, length time.Duration) time.Time {
+	return time.Unix(0, epoch*int64(length))
+}
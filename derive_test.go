@@ -0,0 +1,114 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeriveSecret(t *testing.T) {
+	masterKey := []byte("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")
+
+	// The same inputs always derive the same secret
+	if DeriveSecret(masterKey, 42) != DeriveSecret(masterKey, 42) {
+		t.Error("Expected derivation to be deterministic")
+	}
+
+	const want = "-qxiAS-SDajjgU88tgWOng-V6b18JnILeUU_yVaOiXo"
+	if got := DeriveSecret(masterKey, 42); got != want {
+		t.Errorf("DeriveSecret() = %v, want %v", got, want)
+	}
+
+	// Different epochs and keys derive different secrets
+	if DeriveSecret(masterKey, 42) == DeriveSecret(masterKey, 43) {
+		t.Error("Expected different epochs to derive different secrets")
+	}
+	if DeriveSecret(masterKey, 42) == DeriveSecret([]byte("1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW"), 42) {
+		t.Error("Expected different master keys to derive different secrets")
+	}
+}
+
+func TestSecretsEpoch(t *testing.T) {
+	at := time.Unix(1700000123, 0)
+	if got := SecretsEpoch(at, time.Minute); got != 28333335 {
+		t.Errorf("SecretsEpoch() = %v, want %v", got, 28333335)
+	}
+	if got := SecretsEpoch(at, 0); got != 0 {
+		t.Errorf("SecretsEpoch() with no length = %v, want 0", got)
+	}
+	if start := secretsEpochStart(28333335, time.Minute); !start.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("secretsEpochStart() = %v", start)
+	}
+}
+
+func TestDerivedSecretsSharedBetweenServices(t *testing.T) {
+	config := Config{
+		MasterKey:               []byte("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ"),
+		SecretsRotationInterval: time.Hour,
+	}
+	issuer := NewService(config)
+	verifier := NewService(config)
+
+	current, previous := issuer.GetSecrets()
+	epoch := SecretsEpoch(time.Now(), time.Hour)
+	if current != DeriveSecret(config.MasterKey, epoch) || previous != DeriveSecret(config.MasterKey, epoch-1) {
+		t.Errorf("Expected secrets to be derived from the current epoch")
+	}
+
+	// Rotating within an epoch does not change the secrets
+	issuer.RotateSecrets()
+	if rotated, _ := issuer.GetSecrets(); rotated != current {
+		t.Errorf("Expected secrets to be unchanged within an epoch")
+	}
+
+	response, ok := SolveChallenge(issuer.NewChallengeEncoded(), DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+	if _, err := verifier.Verify(response, VerifyOptions{}); err != nil {
+		t.Errorf("Expected challenge from another instance to be valid, got %v", err)
+	}
+}
+
+func TestDerivedSecretsRotateOnEpochBoundary(t *testing.T) {
+	const epochLength = 50 * time.Millisecond
+	service := NewService(Config{
+		MasterKey:               []byte("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ"),
+		SecretsRotationInterval: epochLength,
+	})
+
+	rotated := make(chan struct{}, 10)
+	service.AddSecretsRotationCallback(func() {
+		rotated <- struct{}{}
+	})
+	defer service.SetSecretsRotationInterval(0)
+
+	initial, _ := service.GetSecrets()
+	<-rotated // the initial rotation
+
+	select {
+	case <-rotated:
+	case <-time.After(10 * epochLength):
+		t.Fatalf("Expected rotation callback at the end of the epoch")
+	}
+
+	current, previous := service.GetSecrets()
+	if current == initial {
+		t.Error("Expected the secrets to change in the new epoch")
+	}
+
+	// Allow for another epoch having started since getting the secrets
+	epoch := SecretsEpoch(time.Now(), epochLength)
+	if current != DeriveSecret(service.config.MasterKey, epoch) {
+		epoch--
+	}
+	if current != DeriveSecret(service.config.MasterKey, epoch) {
+		t.Error("Expected the current secret to be derived from the new epoch")
+	}
+	if previous != DeriveSecret(service.config.MasterKey, epoch-1) {
+		t.Error("Expected the previous secret to be derived from the previous epoch")
+	}
+}
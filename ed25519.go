@@ -0,0 +1,79 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// PublicKey is an Ed25519 public key used to verify challenges, along with an
+// ID which identifies it.
+type PublicKey struct {
+
+	// ID identifies the key. It is generated from the key by PublicKeyID.
+	ID string
+
+	// Key is the Ed25519 public key.
+	Key ed25519.PublicKey
+}
+
+// PublicKeyID generates an ID for the given public key. The ID is derived from
+// the key, so the issuer and the verifiers of challenges generate the same ID.
+func PublicKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(append([]byte("altcha ed25519 key id\n"), key...))
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+func signEd25519(text string, key ed25519.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(text)))
+}
+
+// verifyEd25519 checks the signature using the given public key. The signature
+// must be in its canonical encoding, as replays are prevented by banning the
+// signature as a string, so no other encoding of it can be allowed to verify.
+func verifyEd25519(text, signature string, key ed25519.PublicKey) bool {
+	signatureBytes, err := base64.RawURLEncoding.Strict().DecodeString(signature)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(key, []byte(text), signatureBytes)
+}
+
+// publicKeysFromConfig returns the public keys accepted when verifying. The
+// public key of the signing key comes first, followed by any other keys.
+func publicKeysFromConfig(config Config) (keys []PublicKey) {
+	seen := make(map[string]bool)
+	add := func(key ed25519.PublicKey) {
+		id := PublicKeyID(key)
+		if !seen[id] {
+			seen[id] = true
+			keys = append(keys, PublicKey{ID: id, Key: key})
+		}
+	}
+	if config.SigningKey != nil {
+		add(config.SigningKey.Public().(ed25519.PublicKey))
+	}
+	for _, key := range config.VerificationKeys {
+		add(key)
+	}
+	return keys
+}
+
+// PublicKeys returns the Ed25519 public keys which are accepted when
+// verifying, including the public key of the signing key.
+func (service *Service) PublicKeys() []PublicKey {
+	return append([]PublicKey(nil), service.publicKeys...)
+}
+
+func (service *Service) lookupPublicKey(id string) (key PublicKey, ok bool) {
+	for _, key = range service.publicKeys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return PublicKey{}, false
+}
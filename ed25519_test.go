@@ -0,0 +1,161 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/k42-software/go-altcha/clock"
+	"github.com/k42-software/go-altcha/rand"
+	"testing"
+	"time"
+)
+
+func TestEd25519Signatures(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := NewService(Config{SigningKey: privateKey})
+	verifier := NewService(Config{VerificationKeys: []ed25519.PublicKey{publicKey}})
+
+	msg := issuer.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	if kid := msg.SaltParams().Get("kid"); kid != PublicKeyID(publicKey) {
+		t.Errorf("Expected challenge to carry the public key ID, got %q", kid)
+	}
+
+	// The verifier only has the public key
+	if err := verifier.VerifyMessage(msg, VerifyOptions{}); err != nil {
+		t.Errorf("Expected verifier to accept the challenge, got %v", err)
+	}
+	if err := issuer.VerifyMessage(msg, VerifyOptions{}); err != nil {
+		t.Errorf("Expected issuer to accept its own challenge, got %v", err)
+	}
+	if !verifier.VerifySignature(SHA256, signingInput(msg), msg.Signature) {
+		t.Error("Expected VerifySignature to accept the Ed25519 signature")
+	}
+
+	// A service without the public key rejects the challenge
+	other := NewService(Config{})
+	if err := other.VerifyMessage(msg, VerifyOptions{}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature from a service without the key, got %v", err)
+	}
+
+	// Tampering with the envelope invalidates the signature
+	tampered := msg
+	tampered.Salt += "&expires=4102444800"
+	if verifier.verifyChallenge(SHA256, tampered, Binding{}) {
+		t.Error("Expected tampered challenge to be rejected")
+	}
+}
+
+func TestPublicKeys(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	oldKey, _, _ := ed25519.GenerateKey(nil)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	service := NewService(Config{
+		SigningKey:       privateKey,
+		VerificationKeys: []ed25519.PublicKey{publicKey, oldKey},
+	})
+
+	// The signing key comes first, and duplicates are removed
+	keys := service.PublicKeys()
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 public keys, got %d", len(keys))
+	}
+	if keys[0].ID != PublicKeyID(publicKey) || keys[1].ID != PublicKeyID(oldKey) {
+		t.Errorf("PublicKeys() = %+v", keys)
+	}
+
+	if len(NewService(Config{}).PublicKeys()) != 0 {
+		t.Error("Expected no public keys when Ed25519 is not configured")
+	}
+}
+
+func TestEd25519NonCanonicalSignatureReplay(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(Config{SigningKey: privateKey})
+
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+	if err := service.VerifyMessage(msg, VerifyOptions{PreventReplay: true}); err != nil {
+		t.Fatalf("Expected response to be valid, got %v", err)
+	}
+
+	// The last character of the signature has unused low bits, so changing it
+	// can decode to the same signature, which must not escape the replay ban
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	last := len(msg.Signature) - 1
+	for _, c := range alphabet {
+		if byte(c) == msg.Signature[last] {
+			continue
+		}
+		replayed := msg
+		replayed.Signature = msg.Signature[:last] + string(c)
+		if err := service.VerifyMessage(replayed, VerifyOptions{PreventReplay: true}); err == nil {
+			t.Errorf("Expected response with signature ending %q to be rejected", c)
+		}
+	}
+}
+
+func TestEd25519ReplayAfterRotation(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	service := NewService(Config{SigningKey: privateKey, Clock: fake, ChallengeTTL: -1})
+	service.Start()
+	defer service.Close()
+
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+	if err := service.VerifyMessage(msg, VerifyOptions{PreventReplay: true}); err != nil {
+		t.Fatalf("Expected response to be valid, got %v", err)
+	}
+
+	// Ed25519 challenges without an expiry outlive the rotation of the hmac
+	// keys, so the ban must too
+	fake.Advance(time.Hour)
+	if err := service.VerifyMessage(msg, VerifyOptions{PreventReplay: true}); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected ErrReplay after the hmac keys were rotated, got %v", err)
+	}
+}
+
+func TestEd25519DefaultChallengeTTL(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	service := NewService(Config{SigningKey: privateKey, Clock: fake})
+
+	// The signing key is not rotated, so the challenges must expire on their own
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+	fake.Advance(DefaultChallengeTTL + time.Second)
+	if err := service.VerifyMessage(msg, VerifyOptions{}); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
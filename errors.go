@@ -0,0 +1,49 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import "github.com/pkg/errors"
+
+// These errors are returned when verifying a response, to explain why the
+// response was rejected. The returned errors may wrap these with additional
+// detail, so use errors.Is to check for them.
+var (
+	// ErrMalformed is returned when the response could not be decoded, or is
+	// missing required values.
+	ErrMalformed = errors.New("malformed altcha response")
+
+	// ErrUnsupportedAlgorithm is returned when the response uses a hashing
+	// algorithm which is not supported.
+	ErrUnsupportedAlgorithm = errors.New("unsupported altcha algorithm")
+
+	// ErrBadSolution is returned when the number in the response is not the
+	// solution to the challenge.
+	ErrBadSolution = errors.New("incorrect altcha solution")
+
+	// ErrBadSignature is returned when the signature of the challenge is not
+	// valid, either because the challenge has been tampered with, or because
+	// the secret used to sign it has been rotated out.
+	ErrBadSignature = errors.New("invalid altcha signature")
+
+	// ErrExpired is returned when the challenge has an expiry which has
+	// passed.
+	ErrExpired = errors.New("altcha challenge has expired")
+
+	// ErrReplay is returned when the response has already been used.
+	ErrReplay = errors.New("altcha response has already been used")
+
+	// ErrBindingMismatch is returned when the challenge was bound to a client,
+	// and the response was verified with a different binding. It wraps
+	// ErrBadSignature, as the signature does not match.
+	ErrBindingMismatch = errors.Wrap(ErrBadSignature, "altcha response was bound to another client")
+
+	// ErrWrongScope is returned when the response, or pass token, was issued
+	// for a different scope than the one it is being verified for.
+	ErrWrongScope = errors.New("altcha scope does not match")
+)
+
+// ErrNoSolution is returned when solving a challenge, if no solution was found
+// within the maximum complexity.
+var ErrNoSolution = errors.New("no altcha solution within the maximum complexity")
@@ -0,0 +1,45 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/netip"
+)
+
+// binding returns the attributes of the client which challenges are bound to.
+func (protector *Protector) binding(r *http.Request) (binding altcha.Binding) {
+	if r == nil {
+		return binding
+	}
+	if protector.BindIP {
+		binding.IP = ipNetwork(protector.ClientIP(r))
+	}
+	if protector.BindUserAgent {
+		binding.UserAgent = r.UserAgent()
+	}
+	if protector.SessionID != nil {
+		binding.Session = protector.SessionID(r)
+	}
+	return binding
+}
+
+// ipNetwork returns the network which the IP address is in, which is the /24
+// for IPv4 addresses, and the /64 for IPv6 addresses. Addresses which cannot
+// be parsed are returned unchanged.
+func ipNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.String()
+}
@@ -0,0 +1,95 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProtectHeaderBinding(t *testing.T) {
+	var failure error
+	protector := &Protector{
+		Service:       altcha.NewService(altcha.Config{}),
+		BindIP:        true,
+		BindUserAgent: true,
+		SessionID: func(r *http.Request) string {
+			cookie, err := r.Cookie("session")
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		},
+		OnFailure: func(r *http.Request, err error) { failure = err },
+	}
+	handler := protector.ProtectHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	newRequest := func(remoteAddr, userAgent, session string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("User-Agent", userAgent)
+		r.AddCookie(&http.Cookie{Name: "session", Value: session})
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userAgent  string
+		session    string
+		want       int
+	}{
+		{"Same", "203.0.113.7:1234", "agent", "abc", http.StatusNoContent},
+		{"SameNetwork", "203.0.113.200:5678", "agent", "abc", http.StatusNoContent},
+		{"OtherNetwork", "198.51.100.7:1234", "agent", "abc", http.StatusUnauthorized},
+		{"OtherUserAgent", "203.0.113.7:1234", "other", "abc", http.StatusUnauthorized},
+		{"OtherSession", "203.0.113.7:1234", "agent", "xyz", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failure = nil
+
+			// Get a challenge as the original client
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newRequest("203.0.113.7:1234", "agent", "abc"))
+			msg, err := altcha.DecodeChallenge(w.Header().Get("WWW-Authenticate"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg.Number, _ = msg.Solve(altcha.DefaultComplexity)
+
+			// Send the response from the client under test
+			r := newRequest(test.remoteAddr, test.userAgent, test.session)
+			r.Header.Set("Authorization", msg.String())
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != test.want {
+				t.Errorf("Expected status %d; got %d", test.want, w.Code)
+			}
+			if test.want == http.StatusUnauthorized && !errors.Is(failure, altcha.ErrBindingMismatch) {
+				t.Errorf("Expected ErrBindingMismatch, got %v", failure)
+			}
+		})
+	}
+}
+
+func TestIPNetwork(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7":          "203.0.113.0/24",
+		"::ffff:203.0.113.7":   "203.0.113.0/24",
+		"2001:db8:1:2:3:4:5:6": "2001:db8:1:2::/64",
+		"not an ip":            "not an ip",
+	}
+	for ip, want := range tests {
+		if got := ipNetwork(ip); got != want {
+			t.Errorf("ipNetwork(%q) = %q; want %q", ip, got, want)
+		}
+	}
+}
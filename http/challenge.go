@@ -0,0 +1,69 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"net/http"
+)
+
+// newChallenge creates a new challenge for the client which sent the request,
+// for the given scope, with the complexity chosen by the ComplexityPolicy. The
+// request is nil when called from Protect.
+func (protector *Protector) newChallenge(r *http.Request, scope string) altcha.Message {
+	params := altcha.Parameters{
+		Scope:   scope,
+		Binding: protector.binding(r),
+	}
+	if protector.ComplexityPolicy != nil && r != nil {
+		params.Complexity = protector.ComplexityPolicy.Complexity(protector.clientKey(r))
+	}
+	return protector.service().NewChallengeWithParams(params)
+}
+
+// verify decodes and verifies the response sent with the request, for the
+// given scope, preventing replays. Failures are counted against the client by
+// the FailureLimiter, and the outcome is told to the ComplexityPolicy. The
+// request is nil when called from Protect.
+func (protector *Protector) verify(r *http.Request, response, scope string) (altcha.Message, error) {
+	msg, err := protector.service().Verify(response, altcha.VerifyOptions{
+		PreventReplay:  true,
+		Scope:          scope,
+		AcceptUnscoped: protector.AcceptUnscoped,
+		Binding:        protector.binding(r),
+	})
+	if err != nil {
+		protector.recordFailure(r)
+	}
+	if protector.ComplexityPolicy != nil && r != nil {
+		protector.ComplexityPolicy.Observe(protector.clientKey(r), msg, err)
+	}
+	return msg, err
+}
+
+// scope returns what the challenges for the request are for, which is the
+// path of the request unless a Scope is configured. The request is nil when
+// called from Protect.
+func (protector *Protector) scope(r *http.Request) string {
+	if len(protector.Scope) > 0 || r == nil {
+		return protector.Scope
+	}
+	return r.URL.Path
+}
+
+// scopeParameter is the parameter which tells ServeChallenge, ServeVerify and
+// ForwardAuth the scope, when no Scope is configured.
+const scopeParameter = "scope"
+
+// requestedScope returns the scope for the endpoints which are told it by the
+// request, which is the configured Scope, or else the requested scope. The
+// path of these endpoints is not what the challenges are for, so it is never
+// used as the scope.
+func (protector *Protector) requestedScope(requested string) string {
+	if len(protector.Scope) > 0 {
+		return protector.Scope
+	}
+	return requested
+}
@@ -0,0 +1,149 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses the addresses of trusted proxies, for use as
+// the TrustedProxies of a Protector. Each address is either a CIDR, such as
+// 10.0.0.0/8, or a single IP address.
+func ParseTrustedProxies(addresses ...string) (proxies []netip.Prefix, err error) {
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if strings.Contains(address, "/") {
+			prefix, err := netip.ParsePrefix(address)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing trusted proxy")
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(address)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing trusted proxy")
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// DefaultForwardedHeader is the header which the address of the client is
+// read from, when no ForwardedHeader is configured.
+const DefaultForwardedHeader = "X-Forwarded-For"
+
+// ClientIP returns the IP address of the client which sent the request, using
+// the TrustedProxies and the ForwardedHeader of the protector. See
+// ResolveClientIP for details.
+func (protector *Protector) ClientIP(r *http.Request) string {
+	return ResolveClientIP(r, protector.TrustedProxies, protector.ForwardedHeader)
+}
+
+// ResolveClientIP returns the IP address of the client which sent the
+// request.
+//
+// When the request comes directly from the client, this is the address in
+// r.RemoteAddr. When it comes from one of the trusted proxies, the address of
+// the client is read from the given header, which is the Forwarded header
+// (RFC 7239), or a header with a comma separated list of addresses, such as
+// X-Forwarded-For or X-Real-IP. When empty, DefaultForwardedHeader is used.
+// The header is never read from untrusted peers, as the client could have set
+// it to anything.
+//
+// Only the given header is read, and it must be the header which the trusted
+// proxies set. Any other forwarding header is passed on by the proxies as the
+// client sent it, so reading it would let the client choose its address.
+//
+// The forwarding headers list each hop, with the address each proxy received
+// the request from appended at the end. They are read from the end, skipping
+// the trusted proxies, and the first untrusted address is the client. This
+// means a client cannot spoof its address by sending the header itself, as
+// any addresses it adds are before the address its proxy appended.
+func ResolveClientIP(r *http.Request, trustedProxies []netip.Prefix, header string) string {
+	remote, err := parseHop(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote.String()
+	}
+
+	if len(header) == 0 {
+		header = DefaultForwardedHeader
+	}
+	var hops []string
+	if values := r.Header.Values(header); http.CanonicalHeaderKey(header) == "Forwarded" {
+		hops = forwardedFor(values)
+	} else if len(values) > 0 {
+		hops = strings.Split(strings.Join(values, ","), ",")
+	}
+
+	// Walk back through the hops until one is not a trusted proxy. When a hop
+	// can't be read, the last address which could be read is used, as there
+	// is no way to tell where the request came from before that.
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseHop(hops[i])
+		if err != nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// isTrustedProxy reports whether the address is in one of the trusted
+// proxies.
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for parameters of the elements in the Forwarded
+// headers, in order. Elements without a for parameter are returned as empty
+// strings, so that they are treated as unreadable hops.
+func forwardedFor(headers []string) (hops []string) {
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address from r.RemoteAddr or a forwarding header, which
+// may have a port, and IPv6 addresses may be in brackets.
+func parseHop(hop string) (addr netip.Addr, err error) {
+	hop = strings.TrimSpace(hop)
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	hop = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+	addr, err = netip.ParseAddr(hop)
+	if err != nil {
+		return addr, err
+	}
+	return addr.Unmap(), nil
+}
@@ -0,0 +1,92 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8", "2001:db8::/32", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		headers    map[string]string
+		want       string
+	}{
+		{"Direct", "203.0.113.7:1234", "", nil, "203.0.113.7"},
+		{"UntrustedPeerIgnoresHeaders", "203.0.113.7:1234", "", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"TrustedWithoutHeaders", "10.0.0.1:1234", "", nil, "10.0.0.1"},
+		{"XForwardedFor", "10.0.0.1:1234", "", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"XForwardedForChain", "10.0.0.1:1234", "", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"XForwardedForSpoofed", "10.0.0.1:1234", "", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"XForwardedForAllTrusted", "10.0.0.1:1234", "", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"XForwardedForUnreadable", "10.0.0.1:1234", "", map[string]string{"X-Forwarded-For": "198.51.100.1, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"XRealIP", "192.0.2.1:1234", "X-Real-IP", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"Forwarded", "10.0.0.1:1234", "Forwarded", map[string]string{"Forwarded": `for=198.51.100.1;proto=https, for="10.0.0.2"`}, "198.51.100.1"},
+		{"ForwardedIPv6", "[2001:db8::1]:1234", "Forwarded", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711", for="[2001:db8::2]"`}, "2001:db8:cafe::17"},
+		{"ForwardedObfuscated", "10.0.0.1:1234", "Forwarded", map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.1"},
+		{"MappedIPv4", "[::ffff:10.0.0.1]:1234", "", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"UnreadableRemoteAddr", "pipe", "", nil, "pipe"},
+
+		// The proxy sets X-Forwarded-For, and passes on the other headers as
+		// the client sent them, so they must not be read
+		{"SpoofedForwarded", "10.0.0.1:1234", "", map[string]string{"Forwarded": "for=1.1.1.1", "X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"SpoofedXRealIP", "10.0.0.1:1234", "", map[string]string{"X-Real-IP": "1.1.1.1"}, "10.0.0.1"},
+		{"SpoofedXForwardedFor", "10.0.0.1:1234", "Forwarded", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "1.1.1.1"}, "198.51.100.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+			if got := ResolveClientIP(r, proxies, test.header); got != test.want {
+				t.Errorf("ResolveClientIP() = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.1.2.3/8", " ::ffff:192.0.2.1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxies) != 2 || proxies[0].String() != "10.0.0.0/8" || proxies[1].String() != "192.0.2.1/32" {
+		t.Errorf("ParseTrustedProxies() = %v", proxies)
+	}
+	if _, err := ParseTrustedProxies("not a proxy"); err == nil {
+		t.Error("Expected an error for an invalid address")
+	}
+}
+
+func TestProtectorClientIP(t *testing.T) {
+	proxies, _ := ParseTrustedProxies("10.0.0.0/8")
+	var logged bytes.Buffer
+	protector := &Protector{TrustedProxies: proxies, ErrorLog: log.New(&logged, "", 0)}
+	handler := protector.ProtectForm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("altcha=invalid"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(logged.String(), "from 198.51.100.1 ") {
+		t.Errorf("Expected the client IP to be logged; got %q", logged.String())
+	}
+}
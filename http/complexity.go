@@ -0,0 +1,240 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"github.com/k42-software/go-altcha/clock"
+	"math"
+	"sync"
+	"time"
+)
+
+// ComplexityPolicy chooses the complexity of the challenges issued to each
+// client, so that the difficulty can be adapted to how the client behaves.
+// Clients are identified by the ClientKey of the Protector.
+//
+// Implementations must be concurrency safe.
+type ComplexityPolicy interface {
+
+	// Complexity returns the complexity of a new challenge for the client.
+	// When zero, the complexity of the service is used.
+	Complexity(key string) int
+
+	// Observe is told the outcome of each response from the client. The error
+	// is nil when the response was accepted, and otherwise is the reason it
+	// was rejected. The message is the decoded response, which is empty when
+	// the response could not be decoded.
+	Observe(key string, msg altcha.Message, err error)
+}
+
+// ReputationPolicy is a ComplexityPolicy which makes the challenges harder for
+// clients with recent failed or suspiciously fast responses, and easier for
+// clients with a clean history.
+//
+// Each client has a score, which starts at zero, where the complexity is the
+// base complexity. Each failure, and each response solved faster than
+// MaximumHashRate allows, adds one to the score, which doubles the
+// complexity. Each accepted response subtracts one half from the score. The
+// complexity is kept between MinimumComplexity and MaximumComplexity, and the
+// score decays back towards zero with a half life of HalfLife, so that
+// clients are forgiven, and forgotten, over time.
+//
+// The fields must not be changed once the policy is in use.
+type ReputationPolicy struct {
+
+	// MinimumComplexity is the lowest complexity, for clients with a clean
+	// history.
+	MinimumComplexity int
+
+	// MaximumComplexity is the highest complexity, for abusive clients.
+	MaximumComplexity int
+
+	// HalfLife is how long it takes for the score of a client to decay
+	// half-way back to zero.
+	HalfLife time.Duration
+
+	// MaximumHashRate is the highest number of hashes per second which a
+	// client is expected to compute. Responses solved faster than this, since
+	// the earliest challenge which the client has not yet responded to was
+	// issued, count as failures, as they were likely solved elsewhere, or in
+	// advance. Measuring from the earliest challenge means that clients with
+	// several challenges at once, such as from a second tab or a reload, are
+	// not penalised.
+	MaximumHashRate float64
+
+	complexity int
+	maxClients int
+	clock      clock.Clock
+	mutex      sync.Mutex
+	clients    map[string]*reputation
+	nextTidy   time.Time
+}
+
+type reputation struct {
+	score   float64
+	updated time.Time
+	issued  []time.Time // when the outstanding challenges were issued, oldest first
+}
+
+// maxOutstanding is the number of outstanding challenges tracked for each
+// client. When more are issued, the oldest are forgotten.
+const maxOutstanding = 16
+
+// DefaultReputationPolicySize is the maximum number of clients tracked by a
+// ReputationPolicy. When every client is in use, new clients are given the
+// base complexity without being tracked.
+const DefaultReputationPolicySize = 1 << 16
+
+// DefaultMaximumHashRate is the MaximumHashRate of a ReputationPolicy created
+// by NewReputationPolicy. This is faster than browsers solve challenges.
+const DefaultMaximumHashRate = 10000000
+
+// NewReputationPolicy creates a ReputationPolicy with the given base
+// complexity, for clients without any history. The minimum complexity is a
+// quarter of this, the maximum is 16 times this, and the half life is 10
+// minutes.
+func NewReputationPolicy(complexity int) *ReputationPolicy {
+	if complexity <= altcha.MinimumComplexity {
+		complexity = altcha.DefaultComplexity
+	}
+	return &ReputationPolicy{
+		MinimumComplexity: complexity / 4,
+		MaximumComplexity: complexity * 16,
+		HalfLife:          10 * time.Minute,
+		MaximumHashRate:   DefaultMaximumHashRate,
+		complexity:        complexity,
+		maxClients:        DefaultReputationPolicySize,
+		clock:             clock.System,
+		clients:           make(map[string]*reputation),
+	}
+}
+
+// SetClock sets the clock used to decay the scores. This must be called
+// before the policy is used.
+func (policy *ReputationPolicy) SetClock(clock clock.Clock) {
+	policy.clock = clock
+}
+
+// Complexity returns the complexity of a new challenge for the client.
+func (policy *ReputationPolicy) Complexity(key string) int {
+	now := policy.clock.Now()
+
+	policy.mutex.Lock()
+	defer policy.mutex.Unlock()
+
+	client := policy.client(key, now)
+	if client == nil {
+		return policy.complexity
+	}
+	if len(client.issued) >= maxOutstanding {
+		client.issued = client.issued[1:]
+	}
+	client.issued = append(client.issued, now)
+
+	complexity := float64(policy.complexity) * math.Exp2(client.score)
+	return int(math.Round(math.Max(float64(policy.minimum()), math.Min(complexity, float64(policy.MaximumComplexity)))))
+}
+
+// Observe is told the outcome of each response from the client.
+func (policy *ReputationPolicy) Observe(key string, msg altcha.Message, err error) {
+	now := policy.clock.Now()
+
+	policy.mutex.Lock()
+	defer policy.mutex.Unlock()
+
+	client := policy.client(key, now)
+	if client == nil {
+		return
+	}
+
+	switch {
+	case err != nil:
+		client.score++
+	case policy.tooFast(client, msg, now):
+		client.score++
+	default:
+		client.score -= 0.5
+	}
+
+	// The response answers one of the outstanding challenges, which can't be
+	// told apart, so the earliest is taken as answered
+	if len(msg.Salt) > 0 && len(client.issued) > 0 {
+		client.issued = client.issued[1:]
+	}
+
+	// Keep the score within the range of the complexity, so that a long
+	// history doesn't take longer to decay than it is worth
+	lowest := math.Log2(float64(policy.minimum()) / float64(policy.complexity))
+	highest := math.Log2(float64(policy.MaximumComplexity) / float64(policy.complexity))
+	client.score = math.Max(lowest, math.Min(client.score, highest))
+}
+
+// tooFast reports whether the response was solved faster than the maximum hash
+// rate allows, since the earliest outstanding challenge was issued to the
+// client.
+func (policy *ReputationPolicy) tooFast(client *reputation, msg altcha.Message, now time.Time) bool {
+	if len(client.issued) == 0 || policy.MaximumHashRate <= 0 {
+		return false
+	}
+	elapsed := now.Sub(client.issued[0]).Seconds()
+	return float64(msg.Number) > elapsed*policy.MaximumHashRate
+}
+
+// minimum returns the lowest complexity allowed.
+func (policy *ReputationPolicy) minimum() int {
+	if policy.MinimumComplexity < altcha.MinimumComplexity {
+		return altcha.MinimumComplexity
+	}
+	return policy.MinimumComplexity
+}
+
+// client returns the reputation of the client, with its score decayed to the
+// current time. When there is no room to track the client, it returns nil.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (policy *ReputationPolicy) client(key string, now time.Time) *reputation {
+	client, ok := policy.clients[key]
+	if !ok {
+		if len(policy.clients) >= policy.maxClients && !policy.tidy(now) {
+			return nil
+		}
+		client = &reputation{updated: now}
+		policy.clients[key] = client
+	}
+	policy.decay(client, now)
+	return client
+}
+
+// decay moves the score of the client towards zero, for the time which has
+// passed since it was updated.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (policy *ReputationPolicy) decay(client *reputation, now time.Time) {
+	if elapsed := now.Sub(client.updated); elapsed > 0 && policy.HalfLife > 0 {
+		client.score *= math.Exp2(-float64(elapsed) / float64(policy.HalfLife))
+		client.updated = now
+	}
+}
+
+// tidy forgets the clients whose score has decayed to almost zero, and who
+// have not been issued a challenge recently, and reports whether there is now
+// room for another client. It does nothing more than once per second, so that
+// a full policy isn't repeatedly scanned.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (policy *ReputationPolicy) tidy(now time.Time) bool {
+	if now.Before(policy.nextTidy) {
+		return false
+	}
+	policy.nextTidy = now.Add(time.Second)
+	for key, client := range policy.clients {
+		policy.decay(client, now)
+		if math.Abs(client.score) < 0.01 && (len(client.issued) == 0 || now.Sub(client.issued[len(client.issued)-1]) > policy.HalfLife) {
+			delete(policy.clients, key)
+		}
+	}
+	return len(policy.clients) < policy.maxClients
+}
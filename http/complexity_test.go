@@ -0,0 +1,139 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"github.com/k42-software/go-altcha/clock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReputationPolicy(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	policy := NewReputationPolicy(100000)
+	policy.SetClock(fake)
+
+	if got := policy.Complexity("new"); got != 100000 {
+		t.Errorf("Expected the base complexity for a new client, got %d", got)
+	}
+
+	// Failures double the complexity, up to the maximum
+	policy.Complexity("abusive")
+	policy.Observe("abusive", altcha.Message{}, altcha.ErrBadSolution)
+	if got := policy.Complexity("abusive"); got != 200000 {
+		t.Errorf("Expected the complexity to double after a failure, got %d", got)
+	}
+	for i := 0; i < 10; i++ {
+		policy.Observe("abusive", altcha.Message{}, altcha.ErrBadSolution)
+	}
+	if got := policy.Complexity("abusive"); got != 1600000 {
+		t.Errorf("Expected the maximum complexity, got %d", got)
+	}
+
+	// The history decays with time
+	fake.Advance(policy.HalfLife)
+	if got := policy.Complexity("abusive"); got != 400000 {
+		t.Errorf("Expected the score to have halved, got %d", got)
+	}
+
+	// Successes relax the complexity, down to the minimum
+	policy.Complexity("clean")
+	for i := 0; i < 10; i++ {
+		fake.Advance(time.Second)
+		policy.Observe("clean", altcha.Message{Number: 50000}, nil)
+	}
+	if got := policy.Complexity("clean"); got != 25000 {
+		t.Errorf("Expected the minimum complexity, got %d", got)
+	}
+
+	// Responses solved faster than the maximum hash rate count as failures
+	policy.Complexity("fast")
+	fake.Advance(time.Millisecond)
+	policy.Observe("fast", altcha.Message{Number: 50000}, nil)
+	if got := policy.Complexity("fast"); got != 200000 {
+		t.Errorf("Expected a suspiciously fast response to raise the complexity, got %d", got)
+	}
+
+	// A second tab, or a reload, is measured from the earliest challenge, so
+	// a response to the first challenge is not taken to be too fast
+	policy.Complexity("tabs")
+	fake.Advance(5 * time.Second)
+	policy.Complexity("tabs")
+	fake.Advance(time.Millisecond)
+	policy.Observe("tabs", altcha.Message{Salt: "first", Number: 50000}, nil)
+	if got := policy.Complexity("tabs"); got != 70711 {
+		t.Errorf("Expected a response from a second tab to lower the complexity, got %d", got)
+	}
+}
+
+func TestReputationPolicyBounded(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	policy := NewReputationPolicy(100000)
+	policy.SetClock(fake)
+	policy.maxClients = 2
+
+	policy.Complexity("a")
+	policy.Complexity("b")
+
+	// Untracked clients are given the base complexity when the policy is full
+	policy.Observe("c", altcha.Message{}, altcha.ErrBadSolution)
+	if got := policy.Complexity("c"); got != 100000 || len(policy.clients) != 2 {
+		t.Errorf("Expected an untracked client with the base complexity, got %d with %d clients", got, len(policy.clients))
+	}
+
+	// Forgotten clients make room for new ones
+	fake.Advance(2 * policy.HalfLife)
+	policy.Observe("c", altcha.Message{}, altcha.ErrBadSolution)
+	if got := policy.Complexity("c"); got != 200000 || len(policy.clients) != 1 {
+		t.Errorf("Expected the new client to be tracked, got %d with %d clients", got, len(policy.clients))
+	}
+}
+
+func TestProtectorComplexityPolicy(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	policy := NewReputationPolicy(10000)
+	policy.SetClock(fake)
+	protector := &Protector{
+		Service:          altcha.NewService(altcha.Config{}),
+		ComplexityPolicy: policy,
+	}
+	handler := protector.ProtectForm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	post := func(form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		post("altcha=invalid")
+	}
+	if got := policy.Complexity(protector.ClientIP(httptest.NewRequest("POST", "/", nil))); got != 80000 {
+		t.Errorf("Expected failures to be observed, got complexity %d", got)
+	}
+
+	// The challenges are issued with the complexity of the policy, so some
+	// are harder than the base complexity allows
+	harder := 0
+	for i := 0; i < 20; i++ {
+		msg, err := altcha.DecodeJSON(post("").Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := msg.Solve(10000); !ok {
+			harder++
+		}
+	}
+	if harder == 0 {
+		t.Error("Expected challenges harder than the base complexity")
+	}
+}
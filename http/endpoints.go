@@ -0,0 +1,148 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"encoding/json"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"mime"
+	"net/http"
+)
+
+// Verdict is the result of verifying a response, as written by ServeVerify.
+type Verdict struct {
+
+	// Valid is true when the response is valid.
+	Valid bool `json:"valid"`
+
+	// Reason is a short code explaining why the response is not valid. See
+	// VerdictReason for the codes.
+	Reason string `json:"reason,omitempty"`
+
+	// Error is the error message explaining why the response is not valid.
+	Error string `json:"error,omitempty"`
+}
+
+// reasons are the codes used in a Verdict for each of the errors.
+var reasons = []struct {
+	err  error
+	code string
+}{
+	{altcha.ErrMalformed, "malformed"},
+	{altcha.ErrUnsupportedAlgorithm, "unsupported_algorithm"},
+	{altcha.ErrBadSolution, "bad_solution"},
+	{altcha.ErrBindingMismatch, "binding_mismatch"},
+	{altcha.ErrBadSignature, "bad_signature"},
+	{altcha.ErrExpired, "expired"},
+	{altcha.ErrWrongScope, "wrong_scope"},
+	{altcha.ErrReplay, "replay"},
+}
+
+// VerdictReason returns the short code used in a Verdict for the error
+// returned when verifying a response. The codes are "malformed",
+// "unsupported_algorithm", "bad_solution", "binding_mismatch",
+// "bad_signature", "expired", "wrong_scope" and "replay". Any other error is
+// reported as "invalid".
+func VerdictReason(err error) string {
+	for _, reason := range reasons {
+		if errors.Is(err, reason.err) {
+			return reason.code
+		}
+	}
+	return "invalid"
+}
+
+// ServeChallenge writes a new challenge in JSON format, with the challenge
+// also in the WWW-Authenticate header, in the same way as Protect does when
+// no response is given. This is used by the widget to fetch a challenge.
+//
+// The challenge is issued for the Scope of the Protector. When no Scope is
+// configured, it is issued for the scope query string parameter, so that one
+// endpoint can serve the challenges for several forms, such as
+// /altcha/challenge?scope=contact. Otherwise, it is issued without a scope.
+func ServeChallenge(w http.ResponseWriter, r *http.Request) {
+	defaultProtector.ServeChallenge(w, r)
+}
+
+// ServeChallenge writes a new challenge. See ServeChallenge for details.
+func (protector *Protector) ServeChallenge(w http.ResponseWriter, r *http.Request) {
+	protector.protect(w, r, protector.requestedScope(r.URL.Query().Get(scopeParameter)), "", true)
+}
+
+// ServeVerify verifies a response, with replay prevention, and writes the
+// Verdict as JSON. This allows applications which are not written in Go to
+// verify responses by calling this over HTTP.
+//
+// The response is read from the altcha field of a form or a JSON body, or from
+// the Authorization header. A valid response is answered with a 200 status
+// code, and an invalid response with a 403 status code. When no response is
+// given, the status code is 400. Responses are verified in the same way as by
+// the middleware, so clients which have failed too many times are answered
+// with a 429 status code by the FailureLimiter.
+//
+// The response is verified for the Scope of the Protector. When no Scope is
+// configured, the scope field of the form or JSON body, or the scope query
+// string parameter, is used, so that applications can verify the response
+// for the form it was submitted with, such as /verify?scope=contact.
+func ServeVerify(w http.ResponseWriter, r *http.Request) {
+	defaultProtector.ServeVerify(w, r)
+}
+
+// ServeVerify verifies a response and writes the Verdict. See ServeVerify for
+// details.
+func (protector *Protector) ServeVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Limit the size of the request body to 10 MB
+	r.Body = http.MaxBytesReader(w, r.Body, 10*1048576)
+
+	// Look for the altcha response in the JSON body or the form data
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" || mediaType == "text/json" {
+		err = ParseJSON(r)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		writeVerdict(w, http.StatusBadRequest, Verdict{Reason: "malformed", Error: err.Error()})
+		return
+	}
+	response := r.FormValue("altcha")
+
+	// Fall back to looking in the Authorization header
+	if len(response) == 0 {
+		response = getAuthorizationHeader(r)
+	}
+	if len(response) == 0 {
+		writeVerdict(w, http.StatusBadRequest, Verdict{Reason: "malformed", Error: "no altcha response given"})
+		return
+	}
+
+	// Refuse clients which have failed too many times, without verifying
+	if !protector.allowVerify(w, r) {
+		return
+	}
+
+	_, err = protector.verify(r, response, protector.requestedScope(r.FormValue(scopeParameter)))
+	if err != nil {
+		protector.failed(r, err)
+		writeVerdict(w, http.StatusForbidden, Verdict{Reason: VerdictReason(err), Error: err.Error()})
+		return
+	}
+
+	writeVerdict(w, http.StatusOK, Verdict{Valid: true})
+}
+
+func writeVerdict(w http.ResponseWriter, status int, verdict Verdict) {
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(verdict)
+}
@@ -0,0 +1,173 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeChallenge(t *testing.T) {
+	w := httptest.NewRecorder()
+	ServeChallenge(w, httptest.NewRequest(http.MethodGet, "/challenge", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK; got %v", w.Code)
+	}
+	if _, err := altcha.DecodeJSON(w.Body.Bytes()); err != nil {
+		t.Errorf("Expected a challenge in JSON format: %v", err)
+	}
+	if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), altcha.TextPrefix) {
+		t.Error("Expected a challenge in the WWW-Authenticate header")
+	}
+}
+
+func TestServeVerify(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+
+	solved := func() altcha.Message {
+		msg := service.NewChallenge()
+		msg.Number, _ = msg.Solve(0)
+		return msg
+	}
+	tampered := solved()
+	tampered.Signature = "invalid"
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		header      string
+		wantStatus  int
+		wantReason  string
+	}{
+		{"Form", http.MethodPost, "application/x-www-form-urlencoded", "altcha=" + solved().EncodeWithBase64(), "", http.StatusOK, ""},
+		{"JSON", http.MethodPost, "application/json", `{"altcha":"` + solved().EncodeWithBase64() + `"}`, "", http.StatusOK, ""},
+		{"Header", http.MethodPost, "", "", solved().String(), http.StatusOK, ""},
+		{"BadSignature", http.MethodPost, "application/x-www-form-urlencoded", "altcha=" + tampered.EncodeWithBase64(), "", http.StatusForbidden, "bad_signature"},
+		{"Malformed", http.MethodPost, "application/x-www-form-urlencoded", "altcha=invalid", "", http.StatusForbidden, "malformed"},
+		{"Missing", http.MethodPost, "application/x-www-form-urlencoded", "", "", http.StatusBadRequest, "malformed"},
+		{"MethodNotAllowed", http.MethodGet, "", "", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/verify", strings.NewReader(tc.body))
+			if len(tc.contentType) > 0 {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if len(tc.header) > 0 {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			protector.ServeVerify(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %v; got %v: %s", tc.wantStatus, w.Code, w.Body)
+			}
+			if tc.wantStatus == http.StatusMethodNotAllowed {
+				return
+			}
+			var verdict Verdict
+			if err := json.NewDecoder(w.Body).Decode(&verdict); err != nil {
+				t.Fatal(err)
+			}
+			if verdict.Valid != (tc.wantStatus == http.StatusOK) || verdict.Reason != tc.wantReason {
+				t.Errorf("Unexpected verdict %+v", verdict)
+			}
+		})
+	}
+}
+
+func TestServeVerifyRateLimited(t *testing.T) {
+	var reasons []error
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{
+		Service:        service,
+		FailureLimiter: NewMemoryRateLimiter(2, time.Minute),
+		OnFailure: func(r *http.Request, err error) {
+			reasons = append(reasons, err)
+		},
+	}
+	post := func(response string) int {
+		req := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader("altcha="+url.QueryEscape(response)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		protector.ServeVerify(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := post("invalid"); code != http.StatusForbidden {
+			t.Fatalf("Expected failure %d to be forbidden; got %v", i+1, code)
+		}
+	}
+
+	// Once the client has failed too many times, even a valid response is
+	// refused without being verified
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(0)
+	if code := post(msg.EncodeWithBase64()); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 after too many failures; got %v", code)
+	}
+	if len(reasons) != 3 || !errors.Is(reasons[2], ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited to be reported; got %v", reasons)
+	}
+}
+
+func TestServeVerifyScope(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+
+	// solved fetches a challenge for the scope, as the widget does, and solves it
+	solved := func(scope string) string {
+		w := httptest.NewRecorder()
+		protector.ServeChallenge(w, httptest.NewRequest(http.MethodGet, "/challenge?scope="+scope, nil))
+		response, ok := altcha.SolveChallenge(w.Body.String(), 0)
+		if !ok {
+			t.Fatalf("could not solve challenge: %v", w.Body.String())
+		}
+		return response
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantReason  string
+	}{
+		{"QueryWrongScope", "/verify?scope=password-reset", "application/x-www-form-urlencoded", "altcha=" + url.QueryEscape(solved("newsletter")), http.StatusForbidden, "wrong_scope"},
+		{"QuerySameScope", "/verify?scope=newsletter", "application/x-www-form-urlencoded", "altcha=" + url.QueryEscape(solved("newsletter")), http.StatusOK, ""},
+		{"FormWrongScope", "/verify", "application/x-www-form-urlencoded", "scope=password-reset&altcha=" + url.QueryEscape(solved("newsletter")), http.StatusForbidden, "wrong_scope"},
+		{"JSONWrongScope", "/verify", "application/json", `{"scope":"password-reset","altcha":"` + solved("newsletter") + `"}`, http.StatusForbidden, "wrong_scope"},
+		{"JSONSameScope", "/verify", "application/json", `{"scope":"newsletter","altcha":"` + solved("newsletter") + `"}`, http.StatusOK, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			protector.ServeVerify(w, req)
+
+			var verdict Verdict
+			if err := json.NewDecoder(w.Body).Decode(&verdict); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.wantStatus || verdict.Reason != tc.wantReason {
+				t.Errorf("Expected status %v with reason %q; got %v with %q", tc.wantStatus, tc.wantReason, w.Code, verdict.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// forwardAuthHeader is the request header which may carry the response, in
+// either the base64 or the text encoding.
+const forwardAuthHeader = "X-Altcha"
+
+// forwardAuthCookie is the cookie, and the query string parameter of the
+// original URI, which may carry the response.
+const forwardAuthCookie = "altcha"
+
+// originalURIHeaders are the headers used by reverse proxies to pass the URI
+// of the original request to a forward auth endpoint.
+var originalURIHeaders = []string{
+	"X-Forwarded-Uri", // Traefik
+	"X-Original-URI",  // nginx auth_request, by convention
+	"X-Original-Url",
+}
+
+// ForwardAuth is a handler which implements the forward auth contract used by
+// reverse proxies, such as the auth_request module of nginx and the
+// ForwardAuth middleware of Traefik. This allows applications to be protected
+// without changing them.
+//
+// The response to the challenge is read from, in order: the Authorization
+// header, in text format; the X-Altcha header; the altcha cookie; and the
+// altcha query string parameter of the original URI, which is read from the
+// X-Forwarded-Uri, X-Original-URI or X-Original-Url header. The response is
+// validated with replay prevention.
+//
+// A valid response is answered with a 200 status code, so that the proxy
+// passes the request on. When there is no response, the status code is 401,
+// and when the response is invalid, the status code is 403. In both cases, a
+// new challenge is placed in the WWW-Authenticate header, in the same way as
+// ProtectHeader, which the proxy should pass on to the client.
+//
+// Clients limited by the ChallengeLimiter or the FailureLimiter are answered
+// with a 403 status code and the Retry-After header, rather than the 429 used
+// by the other handlers, as proxies such as nginx treat any status code other
+// than 200, 401 and 403 from the forward auth endpoint as an error.
+//
+// Unless ClientKey is set, clients are told apart by ClientIP. Every request
+// to the handler comes from the proxy, so TrustedProxies must include it, or
+// every client shares the same limits and complexity.
+//
+// Challenges are issued and verified for the Scope of the Protector. When no
+// Scope is configured, the scope query string parameter of the request to the
+// handler is used, so that each route can have its own scope by configuring
+// the proxy with a different address, such as /altcha/auth?scope=admin.
+//
+// The headers used are trusted, so the handler must only be reachable by the
+// reverse proxy.
+func ForwardAuth(w http.ResponseWriter, r *http.Request) {
+	defaultProtector.ForwardAuth(w, r)
+}
+
+// ForwardAuth is a handler which implements the forward auth contract used by
+// reverse proxies. See ForwardAuth for details.
+func (protector *Protector) ForwardAuth(w http.ResponseWriter, r *http.Request) {
+	scope := protector.requestedScope(r.URL.Query().Get(scopeParameter))
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+
+	status := http.StatusUnauthorized
+	if response := forwardAuthResponse(r); len(response) > 0 {
+
+		// refuse clients which have failed too many times
+		if !protector.allowVerifyWithStatus(w, r, http.StatusForbidden) {
+			return
+		}
+
+		// check if the response contains a valid solution to the challenge,
+		// and that it is not a replay
+		_, err := protector.verify(r, response, scope)
+		if err == nil {
+
+			// Success! Let the proxy pass the request on
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		protector.failed(r, err)
+		status = http.StatusForbidden
+	}
+
+	// Failed! Send a new challenge
+	if !protector.allowChallengeWithStatus(w, r, http.StatusForbidden) {
+		return
+	}
+	w.Header().Set("WWW-Authenticate", protector.newChallenge(r, scope).String())
+	w.WriteHeader(status)
+}
+
+// forwardAuthResponse finds the response to the challenge in the request.
+func forwardAuthResponse(r *http.Request) string {
+	if response := getAuthorizationHeader(r); len(response) > 0 {
+		return response
+	}
+	if response := r.Header.Get(forwardAuthHeader); len(response) > 0 {
+		return response
+	}
+	if cookie, err := r.Cookie(forwardAuthCookie); err == nil && len(cookie.Value) > 0 {
+		if response, err := url.PathUnescape(cookie.Value); err == nil {
+			return response
+		}
+	}
+	for _, header := range originalURIHeaders {
+		if uri := r.Header.Get(header); len(uri) > 0 {
+			if original, err := url.ParseRequestURI(uri); err == nil {
+				return original.Query().Get(forwardAuthCookie)
+			}
+		}
+	}
+	return ""
+}
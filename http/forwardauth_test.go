@@ -0,0 +1,162 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwardAuth(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+
+	solved := func() altcha.Message {
+		msg := service.NewChallenge()
+		msg.Number, _ = msg.Solve(0)
+		return msg
+	}
+
+	tests := []struct {
+		name       string
+		prepare    func(r *http.Request)
+		wantStatus int
+	}{
+		{"NoResponse", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"Authorization", func(r *http.Request) {
+			r.Header.Set("Authorization", solved().String())
+		}, http.StatusOK},
+		{"Header", func(r *http.Request) {
+			r.Header.Set("X-Altcha", solved().EncodeWithBase64())
+		}, http.StatusOK},
+		{"Cookie", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "altcha", Value: solved().EncodeWithBase64()})
+		}, http.StatusOK},
+		{"ForwardedURI", func(r *http.Request) {
+			r.Header.Set("X-Forwarded-Uri", "/page?altcha="+url.QueryEscape(solved().EncodeWithBase64()))
+		}, http.StatusOK},
+		{"OriginalURI", func(r *http.Request) {
+			r.Header.Set("X-Original-URI", "/page?altcha="+url.QueryEscape(solved().EncodeWithBase64()))
+		}, http.StatusOK},
+		{"Invalid", func(r *http.Request) {
+			r.Header.Set("X-Altcha", "invalid")
+		}, http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth", nil)
+			tc.prepare(req)
+			w := httptest.NewRecorder()
+			protector.ForwardAuth(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("Expected status %v; got %v", tc.wantStatus, w.Code)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if wantChallenge := tc.wantStatus != http.StatusOK; wantChallenge != strings.HasPrefix(challenge, altcha.TextPrefix) {
+				t.Errorf("Unexpected WWW-Authenticate header %q", challenge)
+			}
+		})
+	}
+}
+
+func TestForwardAuthReplay(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(0)
+
+	for i, wantStatus := range []int{http.StatusOK, http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/auth", nil)
+		req.Header.Set("Authorization", msg.String())
+		w := httptest.NewRecorder()
+		protector.ForwardAuth(w, req)
+		if w.Code != wantStatus {
+			t.Errorf("Request %d: expected status %v; got %v", i+1, wantStatus, w.Code)
+		}
+	}
+}
+
+func TestForwardAuthScope(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+
+	// The challenge is issued for the scope the proxy asks for
+	w := httptest.NewRecorder()
+	protector.ForwardAuth(w, httptest.NewRequest(http.MethodGet, "/auth?scope=admin", nil))
+	msg, err := altcha.DecodeChallenge(w.Header().Get("WWW-Authenticate"))
+	if err != nil {
+		t.Fatalf("could not decode the challenge: %v", err)
+	}
+	if msg.Scope() != "admin" {
+		t.Fatalf("Expected the challenge to be scoped to admin; got %q", msg.Salt)
+	}
+	msg.Number, _ = msg.Solve(0)
+
+	for _, tc := range []struct {
+		target     string
+		wantStatus int
+	}{
+		{"/auth?scope=billing", http.StatusForbidden},
+		{"/auth?scope=admin", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		req.Header.Set("Authorization", msg.String())
+		w := httptest.NewRecorder()
+		protector.ForwardAuth(w, req)
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s: expected status %v; got %v", tc.target, tc.wantStatus, w.Code)
+		}
+	}
+}
+
+func TestForwardAuthRateLimits(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{
+		Service:          service,
+		ChallengeLimiter: NewMemoryRateLimiter(1, time.Minute),
+		FailureLimiter:   NewMemoryRateLimiter(1, time.Minute),
+	}
+	auth := func(remoteAddr, response string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth", nil)
+		req.RemoteAddr = remoteAddr
+		if len(response) > 0 {
+			req.Header.Set("X-Altcha", response)
+		}
+		w := httptest.NewRecorder()
+		protector.ForwardAuth(w, req)
+		return w
+	}
+
+	refused := func(name string, w *httptest.ResponseRecorder) {
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403; got %v", name, w.Code)
+		}
+		if len(w.Header().Get("Retry-After")) == 0 {
+			t.Errorf("%s: expected the Retry-After header", name)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); len(challenge) > 0 {
+			t.Errorf("%s: unexpected WWW-Authenticate header %q", name, challenge)
+		}
+	}
+
+	// Limited clients are refused with a status code the proxy passes on,
+	// rather than 429, which nginx turns into a 500
+	if w := auth("203.0.113.1:1234", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401; got %v", w.Code)
+	}
+	refused("Challenge", auth("203.0.113.1:1234", ""))
+
+	if w := auth("203.0.113.2:1234", "invalid"); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403; got %v", w.Code)
+	}
+	refused("Failure", auth("203.0.113.2:1234", "invalid"))
+}
@@ -0,0 +1,204 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"github.com/k42-software/go-altcha"
+	"github.com/k42-software/go-altcha/rand"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed interstitial.html
+var interstitialHTML string
+
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(interstitialHTML))
+
+// ClearanceCookie is the name of the cookie which holds the clearance issued
+// by ProtectPage.
+const ClearanceCookie = "altcha_clearance"
+
+// DefaultClearanceTTL is how long the clearance issued by ProtectPage lasts,
+// when no ClearanceTTL is configured.
+const DefaultClearanceTTL = 30 * time.Minute
+
+// clearanceVersion is included in the signed clearance, so that it cannot be
+// confused with anything else signed by the service.
+const clearanceVersion = "altcha-go/clearance/v1"
+
+// processClearanceSecret signs the clearances of protectors which have no
+// ClearanceSecret configured.
+var processClearanceSecret = []byte(rand.String(48))
+
+// ProtectPage protects page views using an interstitial challenge page.
+//
+// Browsers without a valid clearance are served a self-contained HTML page,
+// with a 403 status code, which solves a challenge using the embedded widget
+// and posts the solution back to the same URL. When the solution is valid, a
+// signed clearance cookie is set, and the browser is redirected back to the
+// original URL with a GET request. Requests with the clearance then pass
+// through to the protected handler without solving again, until it expires.
+//
+// The clearance lasts for the ClearanceTTL of the Protector. It is signed with
+// the ClearanceSecret, rather than the rotating keys of the service, so that
+// it is not cut short by a rotation. It is only accepted by protectors with
+// the same Scope, and from a client with the same binding, so a clearance
+// earned on one client or for one protector cannot be used elsewhere.
+//
+// Any data posted by a request without a clearance is lost, so this is
+// intended for pages which are viewed, rather than forms which are submitted;
+// use ProtectForm for those.
+func ProtectPage(protected http.Handler) http.Handler {
+	return defaultProtector.ProtectPage(protected)
+}
+
+// ProtectPage protects page views using an interstitial challenge page. See
+// ProtectPage for details.
+func (protector *Protector) ProtectPage(protected http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// Requests with a valid clearance pass straight through
+		if protector.hasClearance(r) {
+			protected.ServeHTTP(w, r)
+			return
+		}
+
+		// Look for the solution posted by the interstitial page
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+			if response := r.PostFormValue("altcha"); len(response) > 0 {
+				if !protector.allowVerify(w, r) {
+					return
+				}
+				_, err := protector.verify(r, response, protector.scope(r))
+				if err == nil {
+
+					// Success! Issue the clearance and return to the page
+					protector.setClearance(w, r)
+					http.Redirect(w, r, sameOriginURI(r), http.StatusSeeOther)
+					return
+				}
+				protector.failed(r, err)
+			}
+		}
+
+		// Serve the interstitial page with a new challenge
+		protector.serveInterstitial(w, r)
+	})
+}
+
+func (protector *Protector) serveInterstitial(w http.ResponseWriter, r *http.Request) {
+	if !protector.allowChallenge(w, r) {
+		return
+	}
+
+	script, _ := files.ReadFile("altcha.min.js")
+
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_ = interstitialTemplate.Execute(w, struct {
+		Script    template.JS
+		Action    string
+		Challenge string
+	}{
+		Script:    template.JS(script),
+		Action:    sameOriginURI(r),
+		Challenge: protector.newChallenge(r, protector.scope(r)).Encode(),
+	})
+}
+
+func (protector *Protector) clearanceTTL() time.Duration {
+	if protector.ClearanceTTL <= 0 {
+		return DefaultClearanceTTL
+	}
+	return protector.ClearanceTTL
+}
+
+func (protector *Protector) clearanceSecret() []byte {
+	if len(protector.ClearanceSecret) == 0 {
+		return processClearanceSecret
+	}
+	return protector.ClearanceSecret
+}
+
+// clearanceSigningInput is the text which is signed for the clearance. The
+// scope and the values of the binding are prefixed by their length, so that
+// there is no ambiguity about where each value ends.
+func clearanceSigningInput(scope string, binding altcha.Binding, expires string) string {
+	var input strings.Builder
+	input.WriteString(clearanceVersion + "\n")
+	for _, value := range []string{scope, binding.IP, binding.UserAgent, binding.Session} {
+		input.WriteString(strconv.Itoa(len(value)) + ":" + value + "\n")
+	}
+	input.WriteString(expires)
+	return input.String()
+}
+
+// signClearance returns the signature of the clearance which expires at the
+// given time, for the client which sent the request. The clearance is signed
+// for the configured Scope, rather than the path of the request, so that it
+// covers every page behind the protector.
+func (protector *Protector) signClearance(r *http.Request, expires string) string {
+	mac := hmac.New(sha256.New, protector.clearanceSecret())
+	mac.Write([]byte(clearanceSigningInput(protector.Scope, protector.binding(r), expires)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setClearance sets a cookie holding the clearance, which is the expiry time
+// and a signature of it, separated by a dot.
+func (protector *Protector) setClearance(w http.ResponseWriter, r *http.Request) {
+	expires := protector.service().Clock().Now().Add(protector.clearanceTTL())
+	expiresText := strconv.FormatInt(expires.Unix(), 10)
+	signature := protector.signClearance(r, expiresText)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ClearanceCookie,
+		Value:    expiresText + "." + signature,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// hasClearance checks if the request has a valid, unexpired clearance.
+func (protector *Protector) hasClearance(r *http.Request) bool {
+	cookie, err := r.Cookie(ClearanceCookie)
+	if err != nil {
+		return false
+	}
+	expiresText, signature, found := strings.Cut(cookie.Value, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil || !protector.service().Clock().Now().Before(time.Unix(expires, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(protector.signClearance(r, expiresText)))
+}
+
+// sameOriginURI returns the URI of the request, in a form which is safe to
+// redirect to, as it cannot refer to another host.
+func sameOriginURI(r *http.Request) string {
+	uri := r.URL.RequestURI()
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") || strings.HasPrefix(uri, "/\\") {
+		return "/"
+	}
+	return uri
+}
@@ -0,0 +1,199 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"github.com/k42-software/go-altcha/clock"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var challengeJSONPattern = regexp.MustCompile(`challengejson="([^"]*)"`)
+
+func TestProtectPage(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+	handler := protector.ProtectPage(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("protected page"))
+	}))
+
+	// Step 1: The interstitial page is served without a clearance
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/page?id=1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403; got %v", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected an HTML page; got %q", ct)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<altcha-widget") || !strings.Contains(body, `action="/page?id=1"`) {
+		t.Errorf("Expected the interstitial page with the widget; got:\n%s", body)
+	}
+
+	// Step 2: Solve the embedded challenge, and post it back
+	match := challengeJSONPattern.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("Expected the challenge to be embedded in the page")
+	}
+	response, ok := altcha.SolveChallenge(html.UnescapeString(match[1]), 0)
+	if !ok {
+		t.Fatalf("could not solve the embedded challenge %s", match[1])
+	}
+	req := httptest.NewRequest(http.MethodPost, "/page?id=1", strings.NewReader(url.Values{"altcha": {response}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/page?id=1" {
+		t.Fatalf("Expected a redirect to the original URL; got %v to %q", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != ClearanceCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Expected a clearance cookie; got %v", cookies)
+	}
+
+	// Step 3: The clearance lets requests through
+	req = httptest.NewRequest(http.MethodGet, "/page?id=1", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "protected page" {
+		t.Errorf("Expected the protected page with the clearance; got %v", w.Code)
+	}
+}
+
+func TestProtectPageInvalidClearance(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+	protector := &Protector{Service: service}
+	handler := protector.ProtectPage(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the protected handler not to be called")
+	}))
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	other := &Protector{Service: service, ClearanceSecret: []byte("other secret")}
+	page := httptest.NewRequest(http.MethodGet, "/page", nil)
+
+	tests := map[string]string{
+		"Malformed":      "malformed",
+		"BadSignature":   future + ".invalid",
+		"OtherSecret":    future + "." + other.signClearance(page, future),
+		"Expired":        expired + "." + protector.signClearance(page, expired),
+		"TamperedExpiry": future + "." + protector.signClearance(page, expired),
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/page", nil)
+			req.AddCookie(&http.Cookie{Name: ClearanceCookie, Value: value})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected the interstitial page; got %v", w.Code)
+			}
+		})
+	}
+
+	// An invalid solution is answered with the interstitial page again
+	req := httptest.NewRequest(http.MethodPost, "/page", strings.NewReader("altcha=invalid"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "<altcha-widget") {
+		t.Errorf("Expected the interstitial page; got %v", w.Code)
+	}
+}
+
+func TestClearanceScopeAndBinding(t *testing.T) {
+	service := altcha.NewService(altcha.Config{})
+	reports := &Protector{Service: service, Scope: "reports", BindIP: true, ClearanceSecret: []byte("secret")}
+	admin := &Protector{Service: service, Scope: "admin", BindIP: true, ClearanceSecret: []byte("secret")}
+
+	request := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+	w := httptest.NewRecorder()
+	reports.setClearance(w, request("203.0.113.1:1234"))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected a clearance cookie; got %v", cookies)
+	}
+
+	tests := []struct {
+		name       string
+		protector  *Protector
+		remoteAddr string
+		want       bool
+	}{
+		{"SameClient", reports, "203.0.113.1:1234", true},
+		{"SameNetwork", reports, "203.0.113.2:1234", true},
+		{"OtherClient", reports, "198.51.100.1:1234", false},
+		{"OtherProtector", admin, "203.0.113.1:1234", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := request(tc.remoteAddr)
+			req.AddCookie(cookies[0])
+			if got := tc.protector.hasClearance(req); got != tc.want {
+				t.Errorf("hasClearance() = %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClearanceOutlivesKeyRotation(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	service := altcha.NewService(altcha.Config{Clock: fake, SecretsRotationInterval: 5 * time.Minute})
+	service.Start()
+	defer service.Close()
+
+	protector := &Protector{Service: service}
+	w := httptest.NewRecorder()
+	protector.setClearance(w, httptest.NewRequest(http.MethodGet, "/page", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected a clearance cookie; got %v", cookies)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.AddCookie(cookies[0])
+
+	// The clearance lasts for the whole TTL, even though the keys of the
+	// service have been rotated out several times
+	fake.Advance(DefaultClearanceTTL - time.Minute)
+	if !protector.hasClearance(req) {
+		t.Error("Expected the clearance to be valid after the keys were rotated")
+	}
+
+	// The expiry is checked against the clock of the service
+	fake.Advance(2 * time.Minute)
+	if protector.hasClearance(req) {
+		t.Error("Expected the clearance to have expired")
+	}
+}
+
+func TestSameOriginURI(t *testing.T) {
+	tests := map[string]string{
+		"/page?id=1":     "/page?id=1",
+		"//evil.example": "/",
+		"/\\evil":        "/%5Cevil",
+	}
+	for uri, want := range tests {
+		path, query, _ := strings.Cut(uri, "?")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL = &url.URL{Path: path, RawQuery: query}
+		if got := sameOriginURI(req); got != want {
+			t.Errorf("sameOriginURI(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// JSONWebKey is an Ed25519 public key in the JSON Web Key format (RFC 8037).
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys in the JSON Web Key Set format.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKeySet converts the public keys into a JSON Web Key Set.
+func NewJSONWebKeySet(keys []altcha.PublicKey) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, JSONWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key.Key),
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		})
+	}
+	return set
+}
+
+// PublicKeys returns the Ed25519 public keys in the set. An error is returned
+// if any of the keys are not Ed25519 public keys, or if the ID of a key does
+// not match the key.
+func (set JSONWebKeySet) PublicKeys() (keys []ed25519.PublicKey, err error) {
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" {
+			return nil, errors.Errorf("unsupported key type %s %s", jwk.KeyType, jwk.Curve)
+		}
+		key, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding key")
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid key length %d", len(key))
+		}
+		if len(jwk.KeyID) > 0 && jwk.KeyID != altcha.PublicKeyID(key) {
+			return nil, errors.Errorf("key id %s does not match key", jwk.KeyID)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ServeKeys serves the Ed25519 public keys of the default service as a JSON
+// Web Key Set, so that services which verify the challenges can fetch the
+// public keys instead of being configured with them.
+func ServeKeys(w http.ResponseWriter, r *http.Request) {
+	defaultProtector.ServeKeys(w, r)
+}
+
+// ServeKeys serves the Ed25519 public keys of the service as a JSON Web Key
+// Set. See ServeKeys for details.
+func (protector *Protector) ServeKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	set := NewJSONWebKeySet(protector.service().PublicKeys())
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(set)
+}
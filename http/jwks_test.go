@@ -0,0 +1,65 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeKeys(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	protector := &Protector{Service: altcha.NewService(altcha.Config{SigningKey: privateKey})}
+
+	w := httptest.NewRecorder()
+	protector.ServeKeys(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 OK; got %v", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/jwk-set+json" {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || jwk.Algorithm != "EdDSA" || jwk.KeyID != altcha.PublicKeyID(publicKey) {
+		t.Errorf("Unexpected key %+v", jwk)
+	}
+
+	// The keys can be used to configure a verifying service
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys[0].Equal(publicKey) {
+		t.Errorf("PublicKeys() = %v", keys)
+	}
+
+	// A key ID which does not match the key is rejected
+	set.Keys[0].KeyID = "wrong"
+	if _, err := set.PublicKeys(); err == nil {
+		t.Error("Expected an error for a mismatched key ID")
+	}
+}
+
+func TestServeKeysMethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	ServeKeys(w, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405; got %v", w.Code)
+	}
+}
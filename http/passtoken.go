@@ -0,0 +1,120 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"context"
+	"github.com/k42-software/go-altcha"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// PassTokenHeader is the header which RequirePassToken reads the pass token
+// from, when it is not in the Authorization header.
+const PassTokenHeader = "X-Altcha-Pass"
+
+// ErrNotVerified is returned by PassToken when the request was not passed
+// through one of the protect middlewares.
+var ErrNotVerified = errors.New("request has no verified altcha response")
+
+type contextKey int
+
+const (
+	verifiedContextKey contextKey = iota
+	passClaimsContextKey
+)
+
+// verified is stored in the context of requests which have a verified
+// response.
+type verified struct {
+	protector *Protector
+	msg       altcha.Message
+}
+
+// withVerified returns the request with the verified response in its context.
+func (protector *Protector) withVerified(r *http.Request, msg altcha.Message) *http.Request {
+	ctx := context.WithValue(r.Context(), verifiedContextKey, verified{protector, msg})
+	return r.WithContext(ctx)
+}
+
+// PassToken issues a pass token for the response verified by ProtectForm,
+// ProtectJSON or ProtectHeader, using the service of the protector which
+// verified it. The protected handler can give the pass token to a downstream
+// service, which accepts it using RequirePassToken, without having to verify
+// the response again. See altcha.IssuePassToken for details.
+//
+// ErrNotVerified is returned when the request was not passed through one of
+// these middlewares.
+func PassToken(r *http.Request, scope string) (token string, err error) {
+	verified, ok := r.Context().Value(verifiedContextKey).(verified)
+	if !ok {
+		return "", ErrNotVerified
+	}
+	return verified.protector.service().IssuePassToken(verified.msg, scope, verified.protector.PassTokenTTL)
+}
+
+// VerifiedMessage returns the response verified by ProtectForm, ProtectJSON or
+// ProtectHeader. When the request was not passed through one of these
+// middlewares, ok is false.
+func VerifiedMessage(r *http.Request) (msg altcha.Message, ok bool) {
+	verified, ok := r.Context().Value(verifiedContextKey).(verified)
+	return verified.msg, ok
+}
+
+// RequirePassToken protects a request using a pass token issued for the
+// given scope, such as by PassToken.
+//
+// The pass token is read from the Authorization header, using the Bearer
+// scheme, or from the X-Altcha-Pass header. When it is missing or invalid, a
+// 401 status code is written. Otherwise, the protected handler is run, and the
+// claims of the pass token are available from PassClaims.
+func RequirePassToken(scope string, protected http.Handler) http.Handler {
+	return defaultProtector.RequirePassToken(scope, protected)
+}
+
+// RequirePassToken protects a request using a pass token issued for the given
+// scope. See RequirePassToken for details.
+func (protector *Protector) RequirePassToken(scope string, protected http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		token := getPassToken(r)
+		if len(token) == 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			http.Error(w, "Missing altcha pass token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := protector.service().VerifyPassToken(token, scope)
+		if err != nil {
+			protector.failed(r, err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid altcha pass token", http.StatusUnauthorized)
+			return
+		}
+
+		// Success! Run the protected handler
+		ctx := context.WithValue(r.Context(), passClaimsContextKey, claims)
+		protected.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// PassClaims returns the claims of the pass token accepted by
+// RequirePassToken. When the request was not passed through RequirePassToken,
+// ok is false.
+func PassClaims(r *http.Request) (claims altcha.PassClaims, ok bool) {
+	claims, ok = r.Context().Value(passClaimsContextKey).(altcha.PassClaims)
+	return claims, ok
+}
+
+// getPassToken reads the pass token from the Authorization header, or the
+// X-Altcha-Pass header.
+func getPassToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(r.Header.Get(PassTokenHeader))
+}
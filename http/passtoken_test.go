@@ -0,0 +1,96 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPassToken(t *testing.T) {
+	service := altcha.NewService(altcha.Config{Secrets: altcha.StaticSecret("secret")})
+	protector := &Protector{Service: service}
+
+	// The protected handler issues a pass token for the downstream service
+	issuer := protector.ProtectForm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if msg, ok := VerifiedMessage(r); !ok || len(msg.Signature) == 0 {
+			t.Errorf("Expected the verified message, got %+v", msg)
+		}
+		token, err := PassToken(r, "checkout")
+		if err != nil {
+			t.Errorf("PassToken() error = %v", err)
+		}
+		_, _ = w.Write([]byte(token))
+	}))
+
+	downstream := protector.RequirePassToken("checkout", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := PassClaims(r)
+		if !ok || claims.Scope != "checkout" {
+			t.Errorf("Expected the claims of the pass token, got %+v", claims)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	msg := service.NewChallengeWithParams(altcha.Parameters{Scope: "/checkout"})
+	msg.Number, _ = msg.Solve(altcha.DefaultComplexity)
+	form := url.Values{"altcha": {msg.EncodeWithBase64()}}
+	req := httptest.NewRequest(http.MethodPost, "/checkout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	issuer.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 OK; got %v", w.Code)
+	}
+	token := w.Body.String()
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"Bearer", "Authorization", "Bearer " + token, http.StatusNoContent},
+		{"Header", PassTokenHeader, token, http.StatusNoContent},
+		{"Missing", "", "", http.StatusUnauthorized},
+		{"Invalid", PassTokenHeader, token + "x", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/internal/checkout", nil)
+			if len(test.header) > 0 {
+				req.Header.Set(test.header, test.value)
+			}
+			w := httptest.NewRecorder()
+			downstream.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Errorf("Expected status %d; got %d", test.want, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("Expected a Bearer challenge, got %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// A pass token for another scope is rejected
+	var failure error
+	other := &Protector{Service: service, OnFailure: func(r *http.Request, err error) { failure = err }}
+	req = httptest.NewRequest(http.MethodGet, "/internal/refund", nil)
+	req.Header.Set(PassTokenHeader, token)
+	w = httptest.NewRecorder()
+	other.RequirePassToken("refund", downstream).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || !errors.Is(failure, altcha.ErrWrongScope) {
+		t.Errorf("Expected 401 with ErrWrongScope; got %d, %v", w.Code, failure)
+	}
+
+	// Requests which were not verified cannot get a pass token
+	if _, err := PassToken(httptest.NewRequest(http.MethodGet, "/", nil), ""); !errors.Is(err, ErrNotVerified) {
+		t.Errorf("Expected ErrNotVerified, got %v", err)
+	}
+}
@@ -6,9 +6,115 @@ package altcha
 
 import (
 	"github.com/k42-software/go-altcha"
+	"log"
 	"net/http"
+	"net/netip"
+	"time"
 )
 
+// Protector holds the configuration used by the protection middleware. The
+// zero value is ready to use, and uses the default altcha service.
+type Protector struct {
+
+	// Service is used to create and validate the challenges. When nil, the
+	// default service is used, which is shared with the package level
+	// functions of the altcha package.
+	Service *altcha.Service
+
+	// OnFailure is called when a response from the client is rejected, with
+	// the error explaining why. See altcha.Verify for the errors which can be
+	// matched. It is called before the failure response is written. The
+	// request is nil when called from Protect, as no request is available.
+	OnFailure func(r *http.Request, err error)
+
+	// ErrorLog is used to log responses from the client which are rejected.
+	// When nil, rejected responses are not logged.
+	ErrorLog *log.Logger
+
+	// ChallengeLimiter limits how often each client may be issued a new
+	// challenge. Clients over the limit are answered with a 429 status code
+	// and a Retry-After header. When nil, challenges are not limited.
+	ChallengeLimiter RateLimiter
+
+	// FailureLimiter limits how often each client may fail verification. A
+	// token is taken for each failure, and once the client has none left, its
+	// responses are answered with a 429 status code and a Retry-After header,
+	// without being verified. When nil, failures are not limited.
+	FailureLimiter RateLimiter
+
+	// ComplexityPolicy chooses the complexity of the challenges issued to
+	// each client, and is told the outcome of each response. When nil, the
+	// complexity of the service is used for every client.
+	ComplexityPolicy ComplexityPolicy
+
+	// ClientKey returns the key which identifies the client, for the rate
+	// limiters and the complexity policy. When nil, the IP address of the
+	// client is used. See ClientIP.
+	ClientKey func(r *http.Request) string
+
+	// ClearanceTTL is how long the clearance issued by ProtectPage lasts.
+	// When zero, DefaultClearanceTTL is used.
+	ClearanceTTL time.Duration
+
+	// ClearanceSecret is the secret used to sign the clearances issued by
+	// ProtectPage. Every instance of a service behind a load balancer must use
+	// the same secret. When empty, a random secret is generated when the
+	// process starts, so clearances do not survive a restart.
+	ClearanceSecret []byte
+
+	// PassTokenTTL is how long the pass tokens issued by PassToken last. When
+	// zero, altcha.DefaultPassTokenTTL is used.
+	PassTokenTTL time.Duration
+
+	// Scope is what the challenges are for, such as a form or a route. A
+	// response is only accepted for the same scope its challenge was issued
+	// for, so that a challenge solved for one form cannot be used on another.
+	// When empty, the path of the request is used, except by ServeChallenge,
+	// ServeVerify and ForwardAuth, which use the scope they are asked for.
+	Scope string
+
+	// AcceptUnscoped accepts responses to challenges issued without a scope,
+	// such as by altcha.NewChallenge, or by ServeChallenge when not asked for
+	// a scope. These can be used on any form, so this should only be set
+	// while moving to scoped challenges.
+	AcceptUnscoped bool
+
+	// TrustedProxies are the networks of the reverse proxies in front of the
+	// server. The forwarding headers are only trusted when the request comes
+	// from one of them. When empty, the address of the client is always the
+	// address of the connection. See ResolveClientIP and ParseTrustedProxies.
+	TrustedProxies []netip.Prefix
+
+	// ForwardedHeader is the header which the trusted proxies set to the
+	// address of the client, such as Forwarded, X-Forwarded-For or X-Real-IP.
+	// Only this header is read. When empty, DefaultForwardedHeader is used.
+	ForwardedHeader string
+
+	// BindIP binds challenges to the network of the client, so that the
+	// response is only accepted from the network the challenge was issued to.
+	// IPv4 addresses are bound to their /24 network, and IPv6 addresses to
+	// their /64 network.
+	BindIP bool
+
+	// BindUserAgent binds challenges to the User-Agent header of the client.
+	BindUserAgent bool
+
+	// SessionID returns the session of the client, which challenges are bound
+	// to. When nil, or when it returns an empty string, challenges are not
+	// bound to a session.
+	SessionID func(r *http.Request) string
+}
+
+// defaultProtector is used by the package level functions.
+var defaultProtector = &Protector{}
+
+func (protector *Protector) service() *altcha.Service {
+	if protector.Service == nil {
+		return altcha.DefaultService()
+	}
+	return protector.Service
+}
+
 // Protect protects a request using the altcha challenge.
 //
 // You must parse the request and pass the challenge string to this function.
@@ -23,11 +129,43 @@ import (
 // If this function returns false, then a response has been written already,
 // and no further action should be taken for the request.
 func Protect(w http.ResponseWriter, challenge string, addAuthenticateHeader bool) (ok bool) {
+	return defaultProtector.Protect(w, challenge, addAuthenticateHeader)
+}
+
+// Protect protects a request using the altcha challenge. See Protect for
+// details.
+func (protector *Protector) Protect(w http.ResponseWriter, challenge string, addAuthenticateHeader bool) (ok bool) {
+	_, ok = protector.protect(w, nil, protector.Scope, challenge, addAuthenticateHeader)
+	return ok
+}
+
+// failed reports a rejected response to the hook and the log.
+func (protector *Protector) failed(r *http.Request, err error) {
+	if protector.OnFailure != nil {
+		protector.OnFailure(r, err)
+	}
+	if protector.ErrorLog != nil {
+		if r != nil {
+			protector.ErrorLog.Printf("altcha: rejected response from %s for %s: %v", protector.ClientIP(r), r.URL.Path, err)
+		} else {
+			protector.ErrorLog.Printf("altcha: rejected response: %v", err)
+		}
+	}
+}
+
+// protect issues a new challenge for the scope when the challenge is empty, and
+// otherwise verifies the response for the scope.
+func (protector *Protector) protect(w http.ResponseWriter, r *http.Request, scope, challenge string, addAuthenticateHeader bool) (msg altcha.Message, ok bool) {
 
 	if len(challenge) == 0 {
 
+		// Refuse clients which have been issued too many challenges
+		if !protector.allowChallenge(w, r) {
+			return msg, false
+		}
+
 		// Create a new challenge
-		newChallenge := altcha.NewChallenge()
+		newChallenge := protector.newChallenge(r, scope)
 
 		// Set the headers
 		w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
@@ -39,17 +177,24 @@ func Protect(w http.ResponseWriter, challenge string, addAuthenticateHeader bool
 
 		// Write the challenge
 		_, _ = w.Write([]byte(newChallenge.Encode()))
-		return false
+		return msg, false
+	}
+
+	// Refuse clients which have failed too many times, without verifying
+	if !protector.allowVerify(w, r) {
+		return msg, false
 	}
 
 	// Validate the response
-	if !altcha.ValidateResponse(challenge, true) {
+	msg, err := protector.verify(r, challenge, scope)
+	if err != nil {
+		protector.failed(r, err)
 		http.Error(w, "Invalid altcha response", http.StatusForbidden)
-		return false
+		return msg, false
 	}
 
 	// Success!
-	return true
+	return msg, true
 }
 
 // ProtectForm protects a request using the altcha challenge.
@@ -58,6 +203,12 @@ func Protect(w http.ResponseWriter, challenge string, addAuthenticateHeader bool
 // r.FormValue("altcha"). This supports passing the challenge information in
 // both the body and the URL query string. See r.ParseForm() for more details.
 func ProtectForm(protected http.Handler) http.Handler {
+	return defaultProtector.ProtectForm(protected)
+}
+
+// ProtectForm protects a request using the altcha challenge. See ProtectForm
+// for details.
+func (protector *Protector) ProtectForm(protected http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// Look for the altcha response in the form data
@@ -73,13 +224,13 @@ func ProtectForm(protected http.Handler) http.Handler {
 		}
 
 		// Run the protection logic
-		ok := Protect(w, challenge, true)
+		msg, ok := protector.protect(w, r, protector.scope(r), challenge, true)
 		if !ok {
 			return
 		}
 
 		// Success! Run the protected handler
-		protected.ServeHTTP(w, r)
+		protected.ServeHTTP(w, protector.withVerified(r, msg))
 	})
 }
 
@@ -88,6 +239,12 @@ func ProtectForm(protected http.Handler) http.Handler {
 // The request body is capped at 10 MB and parsed as JSON. The parsed values
 // are stored in r.Form. The challenge is read from r.FormValue("altcha").
 func ProtectJSON(protected http.Handler) http.Handler {
+	return defaultProtector.ProtectJSON(protected)
+}
+
+// ProtectJSON protects a request using the altcha challenge. See ProtectJSON
+// for details.
+func (protector *Protector) ProtectJSON(protected http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// Limit the size of the request body to 10 MB
@@ -106,13 +263,13 @@ func ProtectJSON(protected http.Handler) http.Handler {
 		}
 
 		// Run the protection logic
-		ok := Protect(w, challenge, true)
+		msg, ok := protector.protect(w, r, protector.scope(r), challenge, true)
 		if !ok {
 			return
 		}
 
 		// Success! Run the protected handler
-		protected.ServeHTTP(w, r)
+		protected.ServeHTTP(w, protector.withVerified(r, msg))
 	})
 }
 
@@ -130,31 +287,39 @@ func ProtectJSON(protected http.Handler) http.Handler {
 //
 // @see https://altcha.org/docs/m2m-altcha
 func ProtectHeader(protected http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return defaultProtector.ProtectHeader(protected)
+}
 
+// ProtectHeader protects a request using the altcha challenge passed through
+// HTTP headers. See ProtectHeader for details.
+func (protector *Protector) ProtectHeader(protected http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get the response from the Authorization header
-		msg, _ := ParseAuthorizationHeader(r)
-
-		// check if the response contains a valid solution to the challenge
-		if msg.IsValidResponse() {
+		if response := getAuthorizationHeader(r); len(response) > 0 {
 
-			// check if the response is a replay
-			// (only do if this it is valid, so someone can't denial-of-service you by
-			// sending a bunch of invalid responses with valid signatures)
-			if !altcha.IsSignatureBanned(msg.Signature) {
+			// refuse clients which have failed too many times
+			if !protector.allowVerify(w, r) {
+				return
+			}
 
-				// add the signature to the list of banned signatures
-				altcha.BanSignature(msg.Signature)
+			// check if the response contains a valid solution to the challenge,
+			// and that it is not a replay
+			msg, err := protector.verify(r, response, protector.scope(r))
+			if err == nil {
 
 				// Success! Run the protected handler
-				protected.ServeHTTP(w, r)
+				protected.ServeHTTP(w, protector.withVerified(r, msg))
 				return
 			}
+			protector.failed(r, err)
 		}
 
 		// Failed! Send a new challenge
+		if !protector.allowChallenge(w, r) {
+			return
+		}
 		w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
-		w.Header().Set("WWW-Authenticate", altcha.NewChallenge().String())
+		w.Header().Set("WWW-Authenticate", protector.newChallenge(r, protector.scope(r)).String())
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	})
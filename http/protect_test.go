@@ -7,11 +7,16 @@ package altcha
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/k42-software/go-altcha"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -81,8 +86,8 @@ func TestProtectForm(t *testing.T) {
 	altcha.RotateSecrets()
 	altcha.RotateSecrets()
 
-	// Create a valid challenge and response
-	challenge := altcha.NewChallengeEncoded()
+	// Create a valid challenge and response for the path being protected
+	challenge := altcha.NewChallengeWithParams(altcha.Parameters{Scope: "/"}).Encode()
 	response, ok := altcha.SolveChallenge(challenge, altcha.DefaultComplexity)
 	if !ok {
 		t.Fatalf("could not solve challenge: %v", challenge)
@@ -147,8 +152,8 @@ func TestProtectJSON(t *testing.T) {
 	altcha.RotateSecrets()
 	altcha.RotateSecrets()
 
-	// Create a valid challenge and response
-	challenge := altcha.NewChallengeEncoded()
+	// Create a valid challenge and response for the path being protected
+	challenge := altcha.NewChallengeWithParams(altcha.Parameters{Scope: "/"}).Encode()
 	response, ok := altcha.SolveChallenge(challenge, altcha.DefaultComplexity)
 	if !ok {
 		t.Fatalf("could not solve challenge: %v", challenge)
@@ -217,8 +222,8 @@ func TestProtectHeader(t *testing.T) {
 	altcha.RotateSecrets()
 	altcha.RotateSecrets()
 
-	// Create a valid challenge and response
-	msg := altcha.NewChallenge()
+	// Create a valid challenge and response for the path being protected
+	msg := altcha.NewChallengeWithParams(altcha.Parameters{Scope: "/"})
 	var ok bool
 	msg.Number, ok = msg.Solve(altcha.DefaultComplexity)
 	if !ok {
@@ -300,3 +305,179 @@ func TestProtectHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestProtectorService(t *testing.T) {
+
+	// Two protectors with independently configured services
+	first := &Protector{Service: altcha.NewService(altcha.Config{})}
+	second := &Protector{Service: altcha.NewService(altcha.Config{})}
+
+	// Create a valid response for the first service
+	challenge := first.Service.NewChallengeEncoded()
+	response, ok := altcha.SolveChallenge(challenge, altcha.DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge: %v", challenge)
+	}
+
+	// The second protector must reject it
+	w := httptest.NewRecorder()
+	if second.Protect(w, response, false) {
+		t.Errorf("Expected second protector to reject the response; got true")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 Forbidden; got %v", w.Code)
+	}
+
+	// The first protector must accept it
+	w = httptest.NewRecorder()
+	if !first.Protect(w, response, false) {
+		t.Errorf("Expected first protector to accept the response; got false")
+	}
+}
+
+func TestProtectHeaderConcurrentReplay(t *testing.T) {
+
+	protector := &Protector{Service: altcha.NewService(altcha.Config{})}
+
+	// Create a valid challenge and response for the path being protected
+	msg := protector.Service.NewChallengeWithParams(altcha.Parameters{Scope: "/"})
+	var ok bool
+	msg.Number, ok = msg.Solve(altcha.DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge: %v", msg.String())
+	}
+	response := msg.String()
+
+	var successes int32
+	handler := protector.ProtectHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&successes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Send the same response from many goroutines at once
+	const attempts = 100
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", response)
+			<-start
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("Expected exactly one successful request, got %d", successes)
+	}
+}
+
+func TestProtectorOnFailure(t *testing.T) {
+
+	var logged bytes.Buffer
+	var reasons []error
+	protector := &Protector{
+		Service: altcha.NewService(altcha.Config{}),
+		OnFailure: func(r *http.Request, err error) {
+			reasons = append(reasons, err)
+		},
+		ErrorLog: log.New(&logged, "", 0),
+	}
+
+	// Create a valid response for the path being protected
+	challenge := protector.Service.NewChallengeWithParams(altcha.Parameters{Scope: "/"}).Encode()
+	response, ok := altcha.SolveChallenge(challenge, altcha.DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge: %v", challenge)
+	}
+
+	handler := protector.ProtectForm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, form := range []string{"altcha=invalid-challenge", "altcha=" + response, "altcha=" + response} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(reasons) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(reasons))
+	}
+	if !errors.Is(reasons[0], altcha.ErrMalformed) {
+		t.Errorf("Expected first failure to be malformed; got %v", reasons[0])
+	}
+	if !errors.Is(reasons[1], altcha.ErrReplay) {
+		t.Errorf("Expected second failure to be a replay; got %v", reasons[1])
+	}
+	if !strings.Contains(logged.String(), altcha.ErrReplay.Error()) {
+		t.Errorf("Expected replay to be logged; got %q", logged.String())
+	}
+}
+
+func TestProtectorScope(t *testing.T) {
+	var reasons []error
+	service := altcha.NewService(altcha.Config{})
+	onFailure := func(r *http.Request, err error) {
+		reasons = append(reasons, err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	challenges := &Protector{Service: service}
+	forms := (&Protector{Service: service, OnFailure: onFailure}).ProtectForm(ok)
+	lenient := (&Protector{Service: service, AcceptUnscoped: true, OnFailure: onFailure}).ProtectForm(ok)
+	passwordReset := (&Protector{Service: service, Scope: "password-reset", OnFailure: onFailure}).ProtectForm(ok)
+
+	// fetch gets a challenge from ServeChallenge, as the widget does, and solves it
+	fetch := func(target string) string {
+		w := httptest.NewRecorder()
+		challenges.ServeChallenge(w, httptest.NewRequest("GET", target, nil))
+		response, ok := altcha.SolveChallenge(w.Body.String(), altcha.DefaultComplexity)
+		if !ok {
+			t.Fatalf("could not solve challenge: %v", w.Body.String())
+		}
+		return response
+	}
+	post := func(handler http.Handler, path, response string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader("altcha="+url.QueryEscape(response)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without a configured scope, a challenge is only accepted on the path it
+	// was requested for
+	response := fetch("/altcha/challenge?scope=/newsletter")
+	if code := post(forms, "/contact", response); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another path; got %v", code)
+	}
+	if code := post(forms, "/newsletter", response); code != http.StatusOK {
+		t.Errorf("Expected status 200 for the same path; got %v", code)
+	}
+	if len(reasons) != 1 || !errors.Is(reasons[0], altcha.ErrWrongScope) {
+		t.Errorf("Expected ErrWrongScope; got %v", reasons)
+	}
+
+	// A challenge issued without a scope is only accepted when allowed
+	if code := post(forms, "/contact", fetch("/altcha/challenge")); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a scope; got %v", code)
+	}
+	if code := post(lenient, "/contact", fetch("/altcha/challenge")); code != http.StatusOK {
+		t.Errorf("Expected status 200 without a scope when accepted; got %v", code)
+	}
+
+	// A configured scope takes precedence over the path and the requested scope
+	if code := post(passwordReset, "/password-reset", fetch("/altcha/challenge?scope=/password-reset")); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for the path; got %v", code)
+	}
+	challenges.Scope = "password-reset"
+	if code := post(passwordReset, "/password-reset", fetch("/altcha/challenge?scope=/newsletter")); code != http.StatusOK {
+		t.Errorf("Expected status 200 for the configured scope; got %v", code)
+	}
+}
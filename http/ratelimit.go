@@ -0,0 +1,232 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha/clock"
+	"github.com/pkg/errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is reported to the OnFailure hook when a response is refused
+// without being verified, because the client has failed too many times.
+var ErrRateLimited = errors.New("too many failed altcha responses")
+
+// RateLimiter limits how often each client may do something, using a token
+// bucket for each key.
+//
+// Implementations must be concurrency safe. Implementations backed by external
+// storage, such as a shared cache, can be used to limit clients across
+// multiple instances of a service.
+type RateLimiter interface {
+
+	// Allow takes a token for the key, and reports whether one was available.
+	// When it was not, retryAfter is how long until one will be.
+	Allow(key string) (allowed bool, retryAfter time.Duration)
+
+	// Check reports whether a token is available for the key, without taking
+	// it. When it is not, retryAfter is how long until one will be.
+	Check(key string) (allowed bool, retryAfter time.Duration)
+}
+
+// DefaultRateLimiterSize is the maximum number of keys tracked by a
+// MemoryRateLimiter.
+const DefaultRateLimiterSize = 1 << 16
+
+// MemoryRateLimiter is an in-memory RateLimiter.
+//
+// Each key has a bucket which holds up to limit tokens, and is refilled at a
+// rate of limit tokens per interval. Buckets which have been refilled are
+// discarded when room is needed, as they are the same as a new bucket. The
+// number of keys tracked is capped, so that memory use is bounded. When every
+// bucket is in use, new keys are allowed without being tracked, rather than
+// limiting clients which have done nothing wrong.
+type MemoryRateLimiter struct {
+	limit    float64
+	rate     float64 // tokens per second
+	maxKeys  int
+	clock    clock.Clock
+	mutex    sync.Mutex
+	buckets  map[string]*tokenBucket
+	nextTidy time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimiter creates a MemoryRateLimiter which allows bursts of up
+// to limit, refilled at a rate of limit per interval.
+func NewMemoryRateLimiter(limit int, interval time.Duration) *MemoryRateLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &MemoryRateLimiter{
+		limit:   float64(limit),
+		rate:    float64(limit) / interval.Seconds(),
+		maxKeys: DefaultRateLimiterSize,
+		clock:   clock.System,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// SetClock sets the clock used to refill the buckets. This must be called
+// before the limiter is used.
+func (limiter *MemoryRateLimiter) SetClock(clock clock.Clock) {
+	limiter.clock = clock
+}
+
+// Allow takes a token for the key, and reports whether one was available.
+func (limiter *MemoryRateLimiter) Allow(key string) (allowed bool, retryAfter time.Duration) {
+	return limiter.take(key, 1)
+}
+
+// Check reports whether a token is available for the key, without taking it.
+func (limiter *MemoryRateLimiter) Check(key string) (allowed bool, retryAfter time.Duration) {
+	return limiter.take(key, 0)
+}
+
+// take takes the given number of tokens for the key, when at least one token
+// is available.
+func (limiter *MemoryRateLimiter) take(key string, tokens float64) (allowed bool, retryAfter time.Duration) {
+	now := limiter.clock.Now()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		if tokens == 0 {
+			return true, 0 // a new bucket is full
+		}
+		if len(limiter.buckets) >= limiter.maxKeys && !limiter.tidy(now) {
+			return true, 0 // no room to track the key
+		}
+		bucket = &tokenBucket{tokens: limiter.limit, updated: now}
+		limiter.buckets[key] = bucket
+	}
+
+	limiter.refill(bucket, now)
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+	}
+	bucket.tokens -= tokens
+	return true, 0
+}
+
+// refill adds the tokens which have accrued since the bucket was updated.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (limiter *MemoryRateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = math.Min(limiter.limit, bucket.tokens+elapsed.Seconds()*limiter.rate)
+		bucket.updated = now
+	}
+}
+
+// tidy discards the buckets which have been refilled, and reports whether
+// there is now room for another key. It does nothing more than once per
+// second, so that a full limiter isn't repeatedly scanned.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (limiter *MemoryRateLimiter) tidy(now time.Time) bool {
+	if now.Before(limiter.nextTidy) {
+		return false
+	}
+	limiter.nextTidy = now.Add(time.Second)
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+		if bucket.tokens >= limiter.limit {
+			delete(limiter.buckets, key)
+		}
+	}
+	return len(limiter.buckets) < limiter.maxKeys
+}
+
+// Len returns the number of keys tracked.
+func (limiter *MemoryRateLimiter) Len() int {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return len(limiter.buckets)
+}
+
+// clientKey returns the key which identifies the client, for rate limiting
+// and the complexity policy.
+func (protector *Protector) clientKey(r *http.Request) string {
+	if protector.ClientKey != nil {
+		return protector.ClientKey(r)
+	}
+	return protector.ClientIP(r)
+}
+
+// allowChallenge reports whether the client may be issued a new challenge.
+// When it may not, a 429 status code is written. The request is nil when
+// called from Protect, which is not limited.
+func (protector *Protector) allowChallenge(w http.ResponseWriter, r *http.Request) bool {
+	return protector.allowChallengeWithStatus(w, r, http.StatusTooManyRequests)
+}
+
+// allowChallengeWithStatus is allowChallenge, writing the given status code
+// when the client may not be issued a new challenge.
+func (protector *Protector) allowChallengeWithStatus(w http.ResponseWriter, r *http.Request, status int) bool {
+	if protector.ChallengeLimiter == nil || r == nil {
+		return true
+	}
+	allowed, retryAfter := protector.ChallengeLimiter.Allow(protector.clientKey(r))
+	if !allowed {
+		writeRateLimited(w, status, retryAfter, "Too many altcha challenges")
+	}
+	return allowed
+}
+
+// allowVerify reports whether the response from the client may be verified,
+// which it may not once the client has failed too many times. When it may
+// not, a 429 status code is written. The request is nil when called from
+// Protect, which is not limited.
+func (protector *Protector) allowVerify(w http.ResponseWriter, r *http.Request) bool {
+	return protector.allowVerifyWithStatus(w, r, http.StatusTooManyRequests)
+}
+
+// allowVerifyWithStatus is allowVerify, writing the given status code when
+// the response from the client may not be verified.
+func (protector *Protector) allowVerifyWithStatus(w http.ResponseWriter, r *http.Request, status int) bool {
+	if protector.FailureLimiter == nil || r == nil {
+		return true
+	}
+	allowed, retryAfter := protector.FailureLimiter.Check(protector.clientKey(r))
+	if !allowed {
+		protector.failed(r, ErrRateLimited)
+		writeRateLimited(w, status, retryAfter, "Too many failed altcha responses")
+	}
+	return allowed
+}
+
+// recordFailure takes a token from the bucket of failures for the client.
+func (protector *Protector) recordFailure(r *http.Request) {
+	if protector.FailureLimiter == nil || r == nil {
+		return
+	}
+	_, _ = protector.FailureLimiter.Allow(protector.clientKey(r))
+}
+
+// writeRateLimited writes the status code for a limited client, with the
+// Retry-After header rounded up to the next second.
+func writeRateLimited(w http.ResponseWriter, status int, retryAfter time.Duration, message string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w, message, status)
+}
@@ -0,0 +1,139 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha"
+	"github.com/k42-software/go-altcha/clock"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	limiter := NewMemoryRateLimiter(3, 3*time.Second)
+	limiter.SetClock(fake)
+
+	// The burst is allowed, and then the client must wait
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("a"); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	allowed, retryAfter := limiter.Allow("a")
+	if allowed || retryAfter != time.Second {
+		t.Errorf("Allow() = %v, %v; want false, 1s", allowed, retryAfter)
+	}
+	if allowed, _ := limiter.Check("a"); allowed {
+		t.Error("Expected Check to agree with Allow")
+	}
+
+	// Other keys have their own bucket
+	if allowed, _ := limiter.Allow("b"); !allowed {
+		t.Error("Expected another key to be allowed")
+	}
+
+	// Tokens are refilled over time, and checking does not take them
+	fake.Advance(time.Second)
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Check("a"); !allowed {
+			t.Fatal("Expected a token to have been refilled")
+		}
+	}
+	if allowed, _ := limiter.Allow("a"); !allowed {
+		t.Error("Expected the refilled token to be taken")
+	}
+	if allowed, _ := limiter.Allow("a"); allowed {
+		t.Error("Expected only one token to have been refilled")
+	}
+}
+
+func TestMemoryRateLimiterBounded(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	limiter := NewMemoryRateLimiter(1, time.Minute)
+	limiter.SetClock(fake)
+	limiter.maxKeys = 2
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+
+	// Untracked keys are allowed when the limiter is full
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("c"); !allowed {
+			t.Error("Expected an untracked key to be allowed")
+		}
+	}
+
+	// Refilled buckets make room for new keys
+	fake.Advance(time.Minute)
+	limiter.Allow("c")
+	if allowed, _ := limiter.Allow("c"); allowed || limiter.Len() != 1 {
+		t.Errorf("Expected the new key to be tracked, with %d keys", limiter.Len())
+	}
+}
+
+func TestProtectorRateLimits(t *testing.T) {
+	var reasons []error
+	protector := &Protector{
+		Service:          altcha.NewService(altcha.Config{}),
+		ChallengeLimiter: NewMemoryRateLimiter(2, time.Minute),
+		FailureLimiter:   NewMemoryRateLimiter(2, time.Minute),
+		OnFailure: func(r *http.Request, err error) {
+			reasons = append(reasons, err)
+		},
+	}
+	handler := protector.ProtectForm(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	post := func(remoteAddr, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Challenges are limited per client
+	for i := 0; i < 2; i++ {
+		if w := post("203.0.113.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected challenge %d to be issued; got %v", i+1, w.Code)
+		}
+	}
+	w := post("203.0.113.1:1234", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429; got %v", w.Code)
+	}
+	if seconds, _ := strconv.Atoi(w.Header().Get("Retry-After")); seconds < 1 || seconds > 30 {
+		t.Errorf("Unexpected Retry-After %q", w.Header().Get("Retry-After"))
+	}
+	if w := post("203.0.113.2:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to be issued a challenge; got %v", w.Code)
+	}
+
+	// Failures are limited per client, and then responses are not verified
+	response, _ := altcha.SolveChallenge(protector.Service.NewChallengeWithParams(altcha.Parameters{Scope: "/"}).Encode(), altcha.DefaultComplexity)
+	for i := 0; i < 2; i++ {
+		if w := post("203.0.113.3:1234", "altcha=invalid"); w.Code != http.StatusForbidden {
+			t.Fatalf("Expected failure %d to be forbidden; got %v", i+1, w.Code)
+		}
+	}
+	if w := post("203.0.113.3:1234", "altcha="+response); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 after too many failures; got %v", w.Code)
+	}
+	if len(reasons) != 3 || !errors.Is(reasons[2], ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited to be reported; got %v", reasons)
+	}
+
+	// The response was not used, so it is still valid from another client
+	if w := post("203.0.113.4:1234", "altcha="+response); w.Code != http.StatusOK {
+		t.Errorf("Expected the response to be accepted; got %v", w.Code)
+	}
+}
@@ -0,0 +1,179 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"bytes"
+	"context"
+	"github.com/k42-software/go-altcha"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultMaxBodySize is the largest request body buffered by a Transport when
+// no size is given.
+const defaultMaxBodySize = 10 * 1048576
+
+// Transport is an http.RoundTripper which solves altcha challenges, as the
+// client side of the M2M Altcha specification.
+//
+// When a response has a 401 status code and an Altcha challenge in the
+// WWW-Authenticate header, the challenge is solved and the request is sent
+// again, with the solution in the Authorization header. Responses with a 200
+// status code and a challenge, as written by Protect, ProtectForm and
+// ProtectJSON when no response is sent, are handled in the same way.
+//
+// When the challenge cannot be solved within the budget, or the request cannot
+// be sent again, the response with the challenge is returned unchanged.
+//
+// @see https://altcha.org/docs/m2m-altcha
+type Transport struct {
+
+	// Base is used to send the requests. When nil, http.DefaultTransport is
+	// used.
+	Base http.RoundTripper
+
+	// MaximumComplexity is the largest number tried when solving a challenge.
+	// Challenges which are harder than this are not solved. When zero, twice
+	// the DefaultComplexity is used.
+	MaximumComplexity int
+
+	// Timeout is the longest time spent solving a challenge. When zero, the
+	// solving is only limited by the context of the request.
+	Timeout time.Duration
+
+	// Workers is the number of goroutines used to solve a challenge. When
+	// zero, GOMAXPROCS goroutines are used.
+	Workers int
+
+	// MaxBodySize is the largest request body which is buffered, so that it
+	// can be sent again. Requests with larger bodies are sent without being
+	// buffered, unless they have a GetBody function, and the challenge is not
+	// solved. When zero, 10 MB is used.
+	MaxBodySize int64
+}
+
+func (transport *Transport) base() http.RoundTripper {
+	if transport.Base == nil {
+		return http.DefaultTransport
+	}
+	return transport.Base
+}
+
+// RoundTrip sends the request, and sends it again with the solution when the
+// response carries a challenge.
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// A request which already has a solution is not solved again, to avoid
+	// looping when the server rejects it.
+	if len(getAuthorizationHeader(req)) > 0 {
+		return transport.base().RoundTrip(req)
+	}
+
+	first, getBody, err := transport.rewindable(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := transport.base().RoundTrip(first)
+	if err != nil || getBody == nil {
+		return resp, err
+	}
+
+	msg, ok := challengeFromResponse(resp)
+	if !ok {
+		return resp, nil
+	}
+
+	ctx := req.Context()
+	if transport.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, transport.Timeout)
+		defer cancel()
+	}
+	solution, err := msg.SolveContext(ctx, altcha.SolveOptions{
+		MaximumComplexity: transport.MaximumComplexity,
+		Workers:           transport.Workers,
+	})
+	if err != nil {
+		if req.Context().Err() != nil {
+			_ = resp.Body.Close()
+			return nil, req.Context().Err()
+		}
+		return resp, nil // too hard, so give up
+	}
+	msg.Number = solution.Number
+
+	body, err := getBody()
+	if err != nil {
+		return resp, nil // cannot send the request again
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	// Send the request again, with the solution
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	retry.Header.Set("Authorization", msg.String())
+	return transport.base().RoundTrip(retry)
+}
+
+// rewindable returns a request to send first, and a function which returns a
+// copy of the body to send again. The function is nil when the body cannot be
+// sent again.
+func (transport *Transport) rewindable(req *http.Request) (first *http.Request, getBody func() (io.ReadCloser, error), err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, func() (io.ReadCloser, error) { return req.Body, nil }, nil
+	}
+	if req.GetBody != nil {
+		return req, req.GetBody, nil
+	}
+
+	maxBodySize := transport.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+
+	// Buffer the body, unless it is too large
+	buffered, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+	if err != nil {
+		_ = req.Body.Close()
+		return nil, nil, err
+	}
+	first = req.Clone(req.Context())
+	if int64(len(buffered)) > maxBodySize {
+		first.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buffered), req.Body), req.Body}
+		return first, nil, nil
+	}
+	_ = req.Body.Close()
+
+	getBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buffered)), nil
+	}
+	first.Body, _ = getBody()
+	return first, getBody, nil
+}
+
+// challengeFromResponse returns the challenge from the WWW-Authenticate header
+// of the response.
+func challengeFromResponse(resp *http.Response) (msg altcha.Message, ok bool) {
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusOK {
+		return msg, false
+	}
+	header := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(header, altcha.TextPrefix) {
+		return msg, false
+	}
+	msg, err := altcha.DecodeText(header)
+	if err != nil || len(msg.Challenge) == 0 {
+		return msg, false
+	}
+	return msg, true
+}
@@ -0,0 +1,135 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	protector := &Protector{Service: altcha.NewService(altcha.Config{Complexity: 5000})}
+
+	// Echo the request body, to check that it is sent again intact
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/header", protector.ProtectHeader(echo))
+	mux.Handle("/form", protector.ProtectForm(echo))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+
+	tests := []struct {
+		name string
+		path string
+		body io.Reader
+		want string
+	}{
+		{"NoBody", "/header", nil, ""},
+		{"RewindableBody", "/header", strings.NewReader("rewindable"), "rewindable"},
+		{"BufferedBody", "/header", io.NopCloser(strings.NewReader("buffered")), "buffered"},
+		{"Form", "/form", nil, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+tc.path, tc.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200 OK; got %v", resp.StatusCode)
+			}
+			if len(resp.Header.Get("WWW-Authenticate")) > 0 {
+				t.Error("expected the challenge to have been solved")
+			}
+			if got, _ := io.ReadAll(resp.Body); string(got) != tc.want {
+				t.Errorf("expected body %q to be sent again; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTransportGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	unsolvable := altcha.Message{
+		Algorithm: "SHA-256",
+		Salt:      "0V5xzYiSFmY1swbb",
+		Challenge: strings.Repeat("0", 64),
+		Signature: "signature",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("WWW-Authenticate", unsolvable.String())
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	t.Run("TooComplex", func(t *testing.T) {
+		requests.Store(0)
+		client := &http.Client{Transport: &Transport{MaximumComplexity: 5000}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || requests.Load() != 1 {
+			t.Errorf("expected the 401 response without retrying; got %v after %d requests", resp.StatusCode, requests.Load())
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		requests.Store(0)
+		client := &http.Client{Transport: &Transport{MaximumComplexity: 1 << 40, Timeout: 50 * time.Millisecond}}
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || requests.Load() != 1 {
+			t.Errorf("expected the 401 response without retrying; got %v after %d requests", resp.StatusCode, requests.Load())
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the solving to stop at the timeout; took %v", elapsed)
+		}
+	})
+}
+
+func TestTransportRejectedSolution(t *testing.T) {
+	var requests atomic.Int32
+	service := altcha.NewService(altcha.Config{Complexity: 5000})
+
+	// The server always rejects the solution
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("WWW-Authenticate", service.NewChallenge().String())
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || requests.Load() != 2 {
+		t.Errorf("expected one retry and then the 401 response; got %v after %d requests", resp.StatusCode, requests.Load())
+	}
+}
@@ -0,0 +1,116 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"sync"
+)
+
+// Key is a secret used to sign challenges, along with an ID which identifies
+// it. The ID is added to the challenges signed using the key, so that the key
+// can be found again when verifying them.
+type Key struct {
+
+	// ID identifies the key. It must not contain whitespace or commas.
+	ID string
+
+	// Secret is used for the hmac.
+	Secret string
+}
+
+// NewKey creates a Key for the given secret, using KeyID to generate the ID.
+func NewKey(secret string) Key {
+	return Key{ID: KeyID(secret), Secret: secret}
+}
+
+// KeyID generates an ID for the given secret. The ID is derived from the
+// secret, so every instance with the same secret generates the same ID, but
+// the ID does not reveal the secret.
+func KeyID(secret string) string {
+	sum := sha256.Sum256([]byte("altcha key id\n" + secret))
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+// Keyring holds the keys used to sign and verify challenges. The newest key is
+// the current key, which is used for signing. A number of previous keys are
+// retained, which are still accepted when verifying.
+//
+// A Keyring is a SecretProvider, and is concurrency safe.
+type Keyring struct {
+	mutex    sync.RWMutex
+	keys     []Key // newest first
+	retained int
+}
+
+// NewKeyring creates a Keyring which retains the given number of previous
+// keys, in addition to the current key. The keys are given newest first.
+func NewKeyring(retained int, keys ...Key) *Keyring {
+	if retained < 0 {
+		retained = 0
+	}
+	keyring := &Keyring{retained: retained}
+	keyring.set(keys)
+	return keyring
+}
+
+// Add adds a new key, which becomes the current key. The oldest key is removed
+// when there are more previous keys than are retained.
+func (keyring *Keyring) Add(key Key) {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	keyring.keys = append([]Key{key}, keyring.keys...)
+	keyring.trim()
+}
+
+// set replaces all the keys.
+func (keyring *Keyring) set(keys []Key) {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	keyring.keys = append([]Key(nil), keys...)
+	keyring.trim()
+}
+
+// WARNING: Ensure the mutex is locked before calling this function.
+func (keyring *Keyring) trim() {
+	if len(keyring.keys) > keyring.retained+1 {
+		keyring.keys = keyring.keys[:keyring.retained+1]
+	}
+}
+
+// Keys returns the keys, newest first.
+func (keyring *Keyring) Keys() []Key {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+	return append([]Key(nil), keyring.keys...)
+}
+
+// Current returns the current key. When the keyring is empty, ok is false.
+func (keyring *Keyring) Current() (key Key, ok bool) {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+	if len(keyring.keys) == 0 {
+		return key, false
+	}
+	return keyring.keys[0], true
+}
+
+// Lookup returns the key with the given ID. When there is no such key, ok is
+// false.
+func (keyring *Keyring) Lookup(id string) (key Key, ok bool) {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+	return lookupKey(keyring.keys, id)
+}
+
+func lookupKey(keys []Key, id string) (key Key, ok bool) {
+	for _, key = range keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
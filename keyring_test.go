@@ -0,0 +1,93 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha/rand"
+	"testing"
+)
+
+func TestKeyID(t *testing.T) {
+	if KeyID("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ") != KeyID("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ") {
+		t.Error("Expected key ID to be deterministic")
+	}
+	if KeyID("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ") == KeyID("1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW") {
+		t.Error("Expected different secrets to have different key IDs")
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keyring := NewKeyring(2)
+
+	if _, ok := keyring.Current(); ok {
+		t.Error("Expected empty keyring to have no current key")
+	}
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		keyring.Add(Key{ID: id, Secret: "secret-" + id})
+	}
+
+	// The current key and two previous keys are retained
+	keys := keyring.Keys()
+	if len(keys) != 3 || keys[0].ID != "d" || keys[1].ID != "c" || keys[2].ID != "b" {
+		t.Errorf("Keys() = %+v", keys)
+	}
+	if current, _ := keyring.Current(); current.ID != "d" {
+		t.Errorf("Current() = %+v, want key d", current)
+	}
+	if key, ok := keyring.Lookup("b"); !ok || key.Secret != "secret-b" {
+		t.Errorf("Lookup(b) = %+v, %v", key, ok)
+	}
+	if _, ok := keyring.Lookup("a"); ok {
+		t.Error("Expected the oldest key to have been removed")
+	}
+}
+
+func TestServiceRetainedKeys(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{RetainedKeys: 3, SecretsRotationInterval: -1})
+
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	// The challenge carries the ID of the key which signed it
+	if kid := msg.SaltParams().Get("kid"); kid != service.currentKey().ID {
+		t.Errorf("Expected challenge to carry the current key ID, got %q", kid)
+	}
+
+	// Valid while the signing key is retained
+	for i := 1; i <= 3; i++ {
+		service.RotateSecrets()
+		if err := service.VerifyMessage(msg, VerifyOptions{}); err != nil {
+			t.Errorf("Expected challenge to be valid after %d rotations, got %v", i, err)
+		}
+	}
+
+	// Invalid once the signing key is no longer retained
+	service.RotateSecrets()
+	if err := service.VerifyMessage(msg, VerifyOptions{}); err == nil {
+		t.Error("Expected challenge to be invalid after 4 rotations")
+	}
+}
+
+func TestVerifyChallengeUnknownKeyID(t *testing.T) {
+	secrets := StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")
+	service := NewService(Config{Secrets: secrets})
+
+	// A challenge which names a key the verifier does not have is rejected,
+	// even when it was signed by one of its keys
+	msg := Message{
+		Algorithm: "SHA-256",
+		Salt:      "0V5xzYiSFmY1swbb?kid=unknown",
+		Challenge: generateHash(SHA256, "0V5xzYiSFmY1swbb?kid=unknown", 34000),
+	}
+	msg.Signature = signChallenge(SHA256, msg, NewKey(secrets.Current))
+	if service.verifyChallenge(SHA256, msg, Binding{}) {
+		t.Error("Expected challenge with unknown key ID to be rejected")
+	}
+}
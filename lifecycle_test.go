@@ -0,0 +1,113 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForGoroutines waits for the number of goroutines to fall to at most n,
+// and returns the number of goroutines.
+func waitForGoroutines(n int) int {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		count := runtime.NumGoroutine()
+		if count <= n || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServiceCloseGoroutineLeak(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	before := waitForGoroutines(runtime.NumGoroutine())
+
+	for i := 0; i < 10; i++ {
+		service := NewService(Config{SecretsRotationInterval: time.Millisecond})
+		service.AddSecretsRotationCallback(func() {})
+		service.Start()
+
+		// Each change of interval must stop the previous ticker goroutine
+		service.SetSecretsRotationInterval(time.Millisecond)
+		service.SetSecretsRotationInterval(2 * time.Millisecond)
+		service.SetSecretsRotationInterval(time.Millisecond)
+
+		derived := NewService(Config{MasterKey: []byte("master key"), SecretsRotationInterval: time.Millisecond})
+		derived.Start()
+
+		time.Sleep(5 * time.Millisecond)
+
+		_ = service.Close()
+		_ = derived.Close()
+	}
+
+	if after := waitForGoroutines(before); after > before {
+		t.Errorf("Expected no goroutines to be leaked, had %d before and %d after", before, after)
+	}
+}
+
+func TestServiceCloseWaitsForCallbacks(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{SecretsRotationInterval: -1})
+	service.Start()
+
+	var finished atomic.Bool
+	service.AddSecretsRotationCallback(func() {
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+	service.RotateSecrets()
+
+	_ = service.Close()
+	if !finished.Load() {
+		t.Error("Expected Close to wait for the rotation callback to return")
+	}
+}
+
+func TestServiceClosedStopsRotation(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{SecretsRotationInterval: time.Millisecond})
+	service.Start()
+	_ = service.Close()
+
+	// The service remains usable, but the secrets no longer change
+	current, _ := service.GetSecrets()
+	time.Sleep(10 * time.Millisecond)
+	if after, _ := service.GetSecrets(); after != current {
+		t.Error("Expected the secrets not to be rotated after Close")
+	}
+
+	// Setting the interval rotates once, but does not restart the rotation
+	service.SetSecretsRotationInterval(time.Millisecond)
+	rotated, _ := service.GetSecrets()
+	if rotated == current {
+		t.Error("Expected SetSecretsRotationInterval to rotate the secrets")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after, _ := service.GetSecrets(); after != rotated {
+		t.Error("Expected no automatic rotation after Close")
+	}
+
+	// A closed service still creates and validates challenges
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+	if !service.IsValidResponse(msg) {
+		t.Error("Expected a closed service to still validate challenges")
+	}
+}
@@ -5,10 +5,14 @@
 package altcha
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"github.com/pkg/errors"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // TextPrefix is the prefix used for the text encoding of the message.
@@ -81,42 +85,103 @@ func (message Message) String() string {
 	return sb.String()
 }
 
+// SaltParams returns the parameters encoded in the salt, such as the expiry.
+func (message Message) SaltParams() url.Values {
+	return saltParams(message.Salt)
+}
+
+// Scope returns what the challenge is for, such as a form or a route. When the
+// challenge has no scope, it is empty.
+func (message Message) Scope() string {
+	return message.SaltParams().Get("scope")
+}
+
+// Expires returns the time at which the challenge expires. When the challenge
+// has no expiry, ok is false.
+func (message Message) Expires() (expires time.Time, ok bool) {
+	value := message.SaltParams().Get("expires")
+	if len(value) == 0 {
+		return expires, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return expires, true // an unreadable expiry is treated as expired
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// IsExpired checks if the challenge has an expiry which has passed. This can
+// be used to tell why IsValidResponse rejected a response.
+func (message Message) IsExpired() bool {
+	return message.isExpiredAt(time.Now())
+}
+
+func (message Message) isExpiredAt(now time.Time) bool {
+	expires, ok := message.Expires()
+	return ok && !now.Before(expires)
+}
+
 // IsValidResponse is used to validate a decoded response from the client.
 func (message Message) IsValidResponse() bool {
+	return defaultService.IsValidResponse(message)
+}
+
+// IsValidResponse is used to validate a decoded response from the client.
+func (service *Service) IsValidResponse(message Message) bool {
+	return service.verifySolution(message, Binding{}) == nil
+}
+
+// Verify is used to verify a decoded response from the client. It returns nil
+// when the response is valid, otherwise an error which explains why it is not.
+// This does not check for replays. See VerifyMessage for details.
+func (message Message) Verify() error {
+	return defaultService.verifySolution(message, Binding{})
+}
+
+// verifySolution checks the response contains a correctly signed, unexpired
+// challenge, and the solution to it. Bound challenges must be verified with the
+// binding they were issued with.
+func (service *Service) verifySolution(message Message, binding Binding) error {
 	algo, ok := AlgorithmFromString(message.Algorithm)
 	if !ok {
-		return false
+		return ErrUnsupportedAlgorithm
 	}
 
 	if message.Number <= 0 {
-		return false
+		return errors.Wrap(ErrMalformed, "number must be positive")
 	}
 
 	if message.Challenge != generateHash(algo, message.Salt, message.Number) {
-		return false
+		return ErrBadSolution
 	}
 
-	return VerifySignature(algo, message.Challenge, message.Signature)
+	if !service.verifyChallenge(algo, message, binding) {
+		// A signature which does not match the binding cannot be told apart
+		// from a forged one, but the most likely cause is another client.
+		if message.SaltParams().Has("bind") {
+			return ErrBindingMismatch
+		}
+		return ErrBadSignature
+	}
+
+	// The expiry is only trusted once the signature has been checked
+	if message.isExpiredAt(service.now()) {
+		return ErrExpired
+	}
+
+	return nil
 }
 
 // Solve attempts to solve the challenge within the given maximum complexity.
+// See SolveContext for a search which can be cancelled.
 func (message Message) Solve(maximumComplexity int) (number int, ok bool) {
-	if maximumComplexity <= 0 {
-		maximumComplexity = DefaultComplexity * 2
-	}
-
-	algo, ok := AlgorithmFromString(message.Algorithm)
-	if !ok {
+	solution, err := message.SolveContext(context.Background(), SolveOptions{
+		MaximumComplexity: maximumComplexity,
+	})
+	if err != nil {
 		return -1, false
 	}
-
-	for i := 1; i <= maximumComplexity; i++ {
-		if message.Challenge == generateHash(algo, message.Salt, i) {
-			return i, true
-		}
-	}
-
-	return -1, false
+	return solution.Number, true
 }
 
 // SolveChallenge is a convenience function which decodes the challenge, solves
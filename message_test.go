@@ -6,7 +6,9 @@ package altcha
 
 import (
 	"github.com/k42-software/go-altcha/rand"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestMessageString(t *testing.T) {
@@ -16,9 +18,9 @@ func TestMessageString(t *testing.T) {
 		Salt:      "0V5xzYiSFmY1swbb",
 		Number:    49500,
 		Challenge: "69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7",
-		Signature: "-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k",
+		Signature: "Hf_I_Sozmm-GoVrkEJ2QCNumEJq3KnU7_62g6w3DZUs",
 	}
-	expectedText := `Altcha algorithm=SHA-256, number=49500, salt=0V5xzYiSFmY1swbb, challenge=69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7, signature=-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k`
+	expectedText := `Altcha algorithm=SHA-256, number=49500, salt=0V5xzYiSFmY1swbb, challenge=69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7, signature=Hf_I_Sozmm-GoVrkEJ2QCNumEJq3KnU7_62g6w3DZUs`
 
 	actualText := originalMsg.String()
 	if actualText != expectedText {
@@ -38,7 +40,7 @@ func TestMessageIsValidResponse(t *testing.T) {
 		Salt:      "0V5xzYiSFmY1swbb",
 		Number:    49500,
 		Challenge: "69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7",
-		Signature: "-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k",
+		Signature: "Hf_I_Sozmm-GoVrkEJ2QCNumEJq3KnU7_62g6w3DZUs",
 	}
 	if !validMsg.IsValidResponse() {
 		t.Error("Expected valid response to be true, got false")
@@ -149,3 +151,28 @@ func TestSolveChallenge(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageExpires(t *testing.T) {
+	tests := []struct {
+		name        string
+		salt        string
+		wantOk      bool
+		wantExpired bool
+	}{
+		{"NoExpiry", "0V5xzYiSFmY1swbb", false, false},
+		{"Future", "0V5xzYiSFmY1swbb?expires=" + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10), true, false},
+		{"Past", "0V5xzYiSFmY1swbb?expires=" + strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10), true, true},
+		{"Unreadable", "0V5xzYiSFmY1swbb?expires=soon", true, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := Message{Salt: tc.salt}
+			if _, ok := msg.Expires(); ok != tc.wantOk {
+				t.Errorf("Message.Expires() ok = %v, want %v", ok, tc.wantOk)
+			}
+			if expired := msg.IsExpired(); expired != tc.wantExpired {
+				t.Errorf("Message.IsExpired() = %v, want %v", expired, tc.wantExpired)
+			}
+		})
+	}
+}
@@ -4,6 +4,13 @@
 
 package altcha
 
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // MinimumComplexity is the minimum complexity allowed.
 // @see https://altcha.org/docs/complexity
 const MinimumComplexity = 1000
@@ -31,10 +38,33 @@ type Parameters struct {
 
 	// Number is the secret number which the client must solve for.
 	Number int `json:"number,omitempty"`
+
+	// Expires is the time at which the challenge expires. It is added to the
+	// salt as a parameter, in the same way as the ALTCHA specification, so it
+	// is covered by the signature. When zero, the challenge only expires when
+	// the secret used to sign it is rotated out.
+	// @see https://altcha.org/docs/server-integration
+	Expires time.Time `json:"expires,omitempty"`
+
+	// Scope is what the challenge is for, such as a form or a route. It is
+	// added to the salt as a parameter, so it is covered by the signature. The
+	// response is rejected when verified for a different scope.
+	Scope string `json:"scope,omitempty"`
+
+	// Binding is the context of the client which the challenge is bound to.
+	// The response is then only accepted when verified with the same binding.
+	// The values are never sent to the client. See Binding for details.
+	Binding Binding `json:"-"`
 }
 
 // Populate generates any missing parameters.
 func (params *Parameters) populate() {
+	params.populateWith(randomInt, randomString)
+}
+
+// populateWith generates any missing parameters, using the given functions to
+// generate the random values.
+func (params *Parameters) populateWith(randomInt func(minimum, maximum int) int, randomString func(length int) string) {
 
 	// Without an algorithm, we use SHA-256.
 	algo, ok := AlgorithmFromString(params.Algorithm)
@@ -48,6 +78,21 @@ func (params *Parameters) populate() {
 		params.Salt = randomString(16)
 	}
 
+	// With an expiry, we add it to the salt, unless it is already there.
+	if !params.Expires.IsZero() {
+		params.Salt = addSaltParam(params.Salt, "expires", strconv.FormatInt(params.Expires.Unix(), 10))
+	}
+
+	// With a scope, we add it to the salt, unless it is already there.
+	if len(params.Scope) > 0 {
+		params.Salt = addSaltParam(params.Salt, "scope", params.Scope)
+	}
+
+	// With a binding, we add the names of the bound attributes to the salt.
+	if names := params.Binding.names(); len(names) > 0 {
+		params.Salt = addSaltParam(params.Salt, "bind", names)
+	}
+
 	// Without a number, we use the complexity to generate a new one.
 	if params.Number <= 0 {
 		if params.Complexity <= MinimumComplexity {
@@ -57,3 +102,27 @@ func (params *Parameters) populate() {
 	}
 
 }
+
+// addSaltParam adds a parameter to the salt, using the same query string style
+// encoding as the ALTCHA specification. Existing parameters are not replaced.
+func addSaltParam(salt, key, value string) string {
+	params := saltParams(salt)
+	if params.Has(key) {
+		return salt
+	}
+	separator := "?"
+	if strings.Contains(salt, "?") {
+		separator = "&"
+	}
+	return salt + separator + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+// saltParams returns the parameters encoded in the salt.
+func saltParams(salt string) url.Values {
+	_, query, found := strings.Cut(salt, "?")
+	if !found {
+		return url.Values{}
+	}
+	params, _ := url.ParseQuery(query)
+	return params
+}
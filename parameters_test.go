@@ -4,7 +4,10 @@
 
 package altcha
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestParametersPopulate(t *testing.T) {
 	params := Parameters{}
@@ -27,3 +30,27 @@ func TestParametersPopulate(t *testing.T) {
 		t.Errorf("Number is not in the expected range: got %d", params.Number)
 	}
 }
+
+func TestParametersPopulateExpires(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+
+	params := Parameters{Salt: "0V5xzYiSFmY1swbb", Expires: expires}
+	params.populate()
+	if params.Salt != "0V5xzYiSFmY1swbb?expires=1700000000" {
+		t.Errorf("Expected expiry to be added to the salt, got %s", params.Salt)
+	}
+
+	// Additional parameters are appended
+	params = Parameters{Salt: "0V5xzYiSFmY1swbb?foo=bar", Expires: expires}
+	params.populate()
+	if params.Salt != "0V5xzYiSFmY1swbb?foo=bar&expires=1700000000" {
+		t.Errorf("Expected expiry to be appended to the salt, got %s", params.Salt)
+	}
+
+	// An existing expiry is not replaced
+	params = Parameters{Salt: "0V5xzYiSFmY1swbb?expires=1600000000", Expires: expires}
+	params.populate()
+	if params.Salt != "0V5xzYiSFmY1swbb?expires=1600000000" {
+		t.Errorf("Expected existing expiry to be kept, got %s", params.Salt)
+	}
+}
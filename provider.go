@@ -0,0 +1,166 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider provides the keys used to sign and verify challenges.
+//
+// By default, each Service generates its own random keys, which means that
+// challenges can only be verified by the process which issued them. When
+// running multiple instances of a service, configure each of them with a
+// SecretProvider which returns the same keys.
+//
+// Implementations must be concurrency safe.
+type SecretProvider interface {
+
+	// Keys returns the keys, newest first. The first key is the current key,
+	// which is used for signing. All the keys are accepted when verifying.
+	Keys() []Key
+}
+
+// StaticSecrets is a SecretProvider which always returns the same secrets.
+type StaticSecrets struct {
+
+	// Current is the secret used for signing and verifying.
+	Current string
+
+	// Previous is an optional secret which is also accepted when verifying.
+	// This allows the secrets to be changed without rejecting challenges
+	// which have already been issued.
+	Previous string
+}
+
+// StaticSecret returns a SecretProvider which always uses the given secret.
+func StaticSecret(secret string) StaticSecrets {
+	return StaticSecrets{Current: secret}
+}
+
+// Keys returns the keys for the current and previous secrets.
+func (secrets StaticSecrets) Keys() []Key {
+	return secretsToKeys(secrets.Current, secrets.Previous)
+}
+
+// secretsToKeys converts the current and previous secrets into keys, skipping
+// any which are empty.
+func secretsToKeys(secrets ...string) (keys []Key) {
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			keys = append(keys, NewKey(secret))
+		}
+	}
+	return keys
+}
+
+// EnvSecrets returns a SecretProvider using the secret from the named
+// environment variable. If an environment variable with the same name and a
+// suffix of "_PREVIOUS" is set, it is used as the previous secret.
+func EnvSecrets(name string) (secrets StaticSecrets, err error) {
+	secrets.Current = strings.TrimSpace(os.Getenv(name))
+	if len(secrets.Current) == 0 {
+		return secrets, errors.Errorf("environment variable %s is not set", name)
+	}
+	secrets.Previous = strings.TrimSpace(os.Getenv(name + "_PREVIOUS"))
+	return secrets, nil
+}
+
+// defaultFileSecretsCheckInterval is how often a FileSecrets checks whether
+// the file has changed.
+const defaultFileSecretsCheckInterval = time.Second
+
+// FileSecrets is a SecretProvider using a secret read from a file. Leading and
+// trailing whitespace is removed from the secret.
+//
+// The file is checked for changes at most once per CheckInterval, and is
+// reloaded when it has changed. On reloading, the secret which was in the file
+// becomes the previous secret, so that challenges which have already been
+// issued continue to be accepted. If the file cannot be reloaded, then the
+// secrets which were already loaded continue to be used.
+type FileSecrets struct {
+	path          string
+	checkInterval time.Duration
+
+	mutex     sync.RWMutex
+	current   string
+	keys      []Key
+	modified  time.Time
+	lastCheck time.Time
+}
+
+// NewFileSecrets creates a FileSecrets which reads the secret from the file
+// at the given path. An error is returned if the file cannot be read, or does
+// not contain a secret.
+func NewFileSecrets(path string) (*FileSecrets, error) {
+	secrets := &FileSecrets{
+		path:          path,
+		checkInterval: defaultFileSecretsCheckInterval,
+	}
+	if err := secrets.reload(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// SetCheckInterval sets how often the file is checked for changes.
+func (secrets *FileSecrets) SetCheckInterval(interval time.Duration) {
+	secrets.mutex.Lock()
+	defer secrets.mutex.Unlock()
+	secrets.checkInterval = interval
+}
+
+// Keys returns the keys for the current and previous secrets, reloading the
+// file first if it has changed.
+func (secrets *FileSecrets) Keys() []Key {
+	secrets.mutex.RLock()
+	due := time.Since(secrets.lastCheck) >= secrets.checkInterval
+	secrets.mutex.RUnlock()
+
+	if due {
+		_ = secrets.reload() // keep using the loaded secrets on failure
+	}
+
+	secrets.mutex.RLock()
+	defer secrets.mutex.RUnlock()
+	return secrets.keys
+}
+
+// reload reads the file if it has been modified since it was last read.
+func (secrets *FileSecrets) reload() error {
+	secrets.mutex.Lock()
+	defer secrets.mutex.Unlock()
+
+	secrets.lastCheck = time.Now()
+
+	info, err := os.Stat(secrets.path)
+	if err != nil {
+		return errors.Wrap(err, "reading secret file")
+	}
+	if len(secrets.current) > 0 && info.ModTime().Equal(secrets.modified) {
+		return nil // not changed
+	}
+
+	content, err := os.ReadFile(secrets.path)
+	if err != nil {
+		return errors.Wrap(err, "reading secret file")
+	}
+	secret := strings.TrimSpace(string(content))
+	if len(secret) == 0 {
+		return errors.Errorf("secret file %s is empty", secrets.path)
+	}
+
+	if secret != secrets.current {
+		secrets.keys = secretsToKeys(secret, secrets.current)
+		secrets.current = secret
+	}
+	secrets.modified = info.ModTime()
+
+	return nil
+}
@@ -0,0 +1,137 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"errors"
+	"github.com/k42-software/go-altcha/clock"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticSecretSharedBetweenServices(t *testing.T) {
+
+	// Two instances of a service configured with the same secret
+	issuer := NewService(Config{Secrets: StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")})
+	verifier := NewService(Config{Secrets: StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")})
+
+	response, ok := SolveChallenge(issuer.NewChallengeEncoded(), DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+	if _, err := verifier.Verify(response, VerifyOptions{}); err != nil {
+		t.Errorf("Expected challenge from another instance to be valid, got %v", err)
+	}
+
+	// Rotating has no effect on a static secret
+	verifier.RotateSecrets()
+	current, previous := verifier.GetSecrets()
+	if current != "0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ" || previous != "" {
+		t.Errorf("Expected static secret to be unchanged, got %s and %s", current, previous)
+	}
+}
+
+func TestEnvSecrets(t *testing.T) {
+	t.Setenv("ALTCHA_TEST_SECRET", "0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ\n")
+	t.Setenv("ALTCHA_TEST_SECRET_PREVIOUS", "1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW")
+
+	secrets, err := EnvSecrets("ALTCHA_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("EnvSecrets() error = %v", err)
+	}
+	current, previous := NewService(Config{Secrets: secrets}).GetSecrets()
+	if current != "0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ" || previous != "1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW" {
+		t.Errorf("EnvSecrets() = %s and %s", current, previous)
+	}
+
+	if _, err = EnvSecrets("ALTCHA_TEST_SECRET_UNSET"); err == nil {
+		t.Error("Expected error for unset environment variable, got nil")
+	}
+}
+
+func TestFileSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+
+	// Missing and empty files are errors
+	if _, err := NewFileSecrets(path); err == nil {
+		t.Error("Expected error for missing file, got nil")
+	}
+	if err := os.WriteFile(path, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSecrets(path); err == nil {
+		t.Error("Expected error for empty file, got nil")
+	}
+
+	if err := os.WriteFile(path, []byte("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := NewFileSecrets(path)
+	if err != nil {
+		t.Fatalf("NewFileSecrets() error = %v", err)
+	}
+	secrets.SetCheckInterval(0)
+	service := NewService(Config{Secrets: secrets})
+
+	current, previous := service.GetSecrets()
+	if current != "0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ" || previous != "" {
+		t.Errorf("GetSecrets() = %s and %s", current, previous)
+	}
+
+	// Changing the file rotates the secrets
+	if err = os.WriteFile(path, []byte("1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(time.Second) // ensure the modification time changes
+	if err = os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	current, previous = service.GetSecrets()
+	if current != "1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW" || previous != "0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ" {
+		t.Errorf("GetSecrets() after change = %s and %s", current, previous)
+	}
+
+	// Removing the file keeps the loaded secrets
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	current, _ = service.GetSecrets()
+	if current != "1K7xwZjTHfM2tRbbLwJnBgbXcw8zKwXW" {
+		t.Errorf("Expected loaded secret to be kept, got %s", current)
+	}
+}
+
+func TestReplayTTLWithSecretProvider(t *testing.T) {
+	// Challenges signed using a SecretProvider remain valid indefinitely, so
+	// they must be banned for as long as the store will hold them
+	service := NewService(Config{Secrets: StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")})
+	if ttl := service.replayTTL(); ttl != maxReplayTTL {
+		t.Errorf("replayTTL() = %v; want %v", ttl, maxReplayTTL)
+	}
+}
+
+func TestSecretProviderDefaultChallengeTTL(t *testing.T) {
+	// The secrets of a SecretProvider are not rotated by the service, so the
+	// challenges must expire on their own
+	fake := clock.NewFake(fakeEpoch)
+	service := NewService(Config{Secrets: StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ"), Clock: fake})
+	msg := service.NewChallenge()
+	if expires, ok := msg.Expires(); !ok || !expires.Equal(fakeEpoch.Add(DefaultChallengeTTL)) {
+		t.Fatalf("Expected the challenge to expire after DefaultChallengeTTL, got %v", expires)
+	}
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+	fake.Advance(DefaultChallengeTTL + time.Second)
+	if err := service.VerifyMessage(msg, VerifyOptions{}); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+
+	// A negative ChallengeTTL disables the expiry
+	service = NewService(Config{Secrets: StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ"), ChallengeTTL: -1})
+	if _, ok := service.NewChallenge().Expires(); ok {
+		t.Error("Expected no expiry with a negative ChallengeTTL")
+	}
+}
@@ -6,14 +6,22 @@ package rand
 
 import (
 	"crypto/rand"
+	"io"
 	"math/big"
 )
 
 func Int(minimum, maximum int) int {
+	return IntFrom(rand.Reader, minimum, maximum)
+}
+
+// IntFrom returns a random number in the range [minimum, maximum), using the
+// given source of entropy. It panics if the reader returns an error, such as
+// io.EOF when a finite reader runs out, as there is no safe number to return.
+func IntFrom(reader io.Reader, minimum, maximum int) int {
 	maxBigInt := big.NewInt(int64(maximum - minimum))
-	number, err := rand.Int(rand.Reader, maxBigInt)
+	number, err := rand.Int(reader, maxBigInt)
 	if err != nil {
-		panic(err) // this should never happen
+		panic(err) // the reader has failed, or run out of entropy
 	}
 	return minimum + int(number.Int64())
 }
@@ -5,6 +5,7 @@
 package rand
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -47,3 +48,20 @@ func TestIntEdgeCases(t *testing.T) {
 }
 
 // Additional tests for specific error scenarios and distribution can be added as needed
+
+func TestIntFromDeterministic(t *testing.T) {
+	first := IntFrom(bytes.NewReader(make([]byte, 64)), 0, 1000)
+	second := IntFrom(bytes.NewReader(make([]byte, 64)), 0, 1000)
+	if first != second {
+		t.Errorf("Expected the same source to give the same number, got %d and %d", first, second)
+	}
+}
+
+func TestIntFromPanicsWhenExhausted(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic when the reader has run out")
+		}
+	}()
+	IntFrom(bytes.NewReader(nil), 0, 1000)
+}
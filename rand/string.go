@@ -4,15 +4,26 @@
 
 package rand
 
+import (
+	"crypto/rand"
+	"io"
+)
+
 const alphabet = "0987654321ZYXWVUTSRQPONMLKJIHGFEDCBAzyxwvutsrqponmlkjihgfedcba"
 
 func String(length int) string {
+	return StringFrom(rand.Reader, length)
+}
+
+// StringFrom returns a random alphanumeric string of the given length, using
+// the given source of entropy.
+func StringFrom(reader io.Reader, length int) string {
 	if length <= 0 {
 		return ""
 	}
 	b := make([]byte, length)
 	for i := range b {
-		b[i] = alphabet[Int(0, len(alphabet))]
+		b[i] = alphabet[IntFrom(reader, 0, len(alphabet))]
 	}
 	return string(b)
 }
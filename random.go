@@ -11,3 +11,25 @@ var (
 	randomInt    = rand.Int    // func(minimum, maximum int) int
 	randomString = rand.String // func(length int) string
 )
+
+// randomInt returns a random number in the range [minimum, maximum), using the
+// configured source of entropy.
+func (service *Service) randomInt(minimum, maximum int) int {
+	if service.config.Rand == nil {
+		return randomInt(minimum, maximum)
+	}
+	service.randMutex.Lock()
+	defer service.randMutex.Unlock()
+	return rand.IntFrom(service.config.Rand, minimum, maximum)
+}
+
+// randomString returns a random string of the given length, using the
+// configured source of entropy.
+func (service *Service) randomString(length int) string {
+	if service.config.Rand == nil {
+		return randomString(length)
+	}
+	service.randMutex.Lock()
+	defer service.randMutex.Unlock()
+	return rand.StringFrom(service.config.Rand, length)
+}
@@ -5,79 +5,307 @@
 package altcha
 
 import (
-	"sort"
+	"github.com/k42-software/go-altcha/clock"
+	"hash/maphash"
 	"sync"
+	"time"
 )
 
-const defaultBanSliceSize = 10
+// ReplayStore records the signatures of responses which have already been
+// used, so that they cannot be used again.
+//
+// Implementations must be concurrency safe. Implementations backed by external
+// storage, such as a shared cache, can be used to prevent replays across
+// multiple instances of a service.
+type ReplayStore interface {
 
-var (
-	bannedSignatures [][]string
-	bannedMutex      = &sync.RWMutex{}
-)
+	// Ban marks the signature as used until the ttl has elapsed, and reports
+	// whether the signature was already banned. The check and the ban must
+	// happen atomically, so that when called concurrently with the same
+	// signature, only one call reports that it was not already banned.
+	Ban(signature string, ttl time.Duration) (alreadyBanned bool)
 
-// BanSignature adds the given signature to the list of banned signatures.
-func BanSignature(signature string) {
-	if len(signature) == 0 {
-		return
+	// IsBanned checks if the signature is currently banned.
+	IsBanned(signature string) bool
+}
+
+// DefaultReplayStoreSize is the maximum number of signatures held by a
+// MemoryReplayStore when no size is given.
+const DefaultReplayStoreSize = 1 << 20
+
+const replayStoreShards = 32
+
+// minReplayShardSize is the smallest number of signatures held by each shard,
+// so that small stores are split into fewer shards.
+const minReplayShardSize = 64
+
+const maxReplayTTL = 100 * 365 * 24 * time.Hour
+
+// MemoryReplayStore is an in-memory ReplayStore.
+//
+// Signatures are spread across a number of independently locked shards, and
+// each shard is a map, so checking and banning signatures takes constant time
+// regardless of how many signatures are banned.
+//
+// The number of signatures held is capped, so that memory use is bounded.
+// When a shard is full, its expired signatures are evicted first. When it is
+// full of unexpired signatures, the oldest signature in the shard is evicted
+// to make room, which allows that signature to be replayed. Size the store so
+// that it holds every signature banned within the lifetime of a challenge.
+type MemoryReplayStore struct {
+	seed   maphash.Seed
+	clock  clock.Clock
+	shards []replayShard
+}
+
+type replayShard struct {
+	mutex      sync.Mutex
+	maxEntries int
+	expiries   map[string]time.Time
+	queue      []replayEntry // signatures in the order that they were banned
+	head       int           // index of the oldest signature in the queue
+	earliest   time.Time     // no signature in the queue expires before this
+}
+
+type replayEntry struct {
+	signature string
+	expiry    time.Time
+}
+
+// NewMemoryReplayStore creates a new MemoryReplayStore which holds at most
+// maxEntries signatures. When maxEntries is zero or less, the
+// DefaultReplayStoreSize is used.
+func NewMemoryReplayStore(maxEntries int) *MemoryReplayStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultReplayStoreSize
+	}
+	shards := maxEntries / minReplayShardSize
+	if shards < 1 {
+		shards = 1
+	} else if shards > replayStoreShards {
+		shards = replayStoreShards
 	}
 
-	bannedMutex.Lock()
-	defer bannedMutex.Unlock()
+	store := &MemoryReplayStore{
+		seed:   maphash.MakeSeed(),
+		clock:  clock.System,
+		shards: make([]replayShard, shards),
+	}
+	for i := range store.shards {
+		// Share out the remainder, so that the shards hold exactly maxEntries
+		store.shards[i].maxEntries = maxEntries / shards
+		if i < maxEntries%shards {
+			store.shards[i].maxEntries++
+		}
+		store.shards[i].expiries = make(map[string]time.Time)
+	}
+	return store
+}
+
+// SetClock sets the clock used to tell when signatures expire. This must be
+// called before the store is used.
+func (store *MemoryReplayStore) SetClock(clock clock.Clock) {
+	store.clock = clock
+}
+
+func (store *MemoryReplayStore) shard(signature string) *replayShard {
+	return &store.shards[maphash.String(store.seed, signature)%uint64(len(store.shards))]
+}
 
-	if len(bannedSignatures) == 0 {
-		bannedSignatures = make([][]string, 1, 2)
-		bannedSignatures[0] = []string{}
+// Ban marks the signature as used until the ttl has elapsed, and reports
+// whether the signature was already banned.
+func (store *MemoryReplayStore) Ban(signature string, ttl time.Duration) (alreadyBanned bool) {
+	now := store.clock.Now()
+	shard := store.shard(signature)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if expiry, ok := shard.expiries[signature]; ok && now.Before(expiry) {
+		return true
 	}
 
-	if len(bannedSignatures[0]) == 0 {
-		bannedSignatures[0] = make([]string, 0, defaultBanSliceSize)
+	shard.evict(now)
+	expiry := now.Add(ttl)
+	shard.expiries[signature] = expiry
+	shard.queue = append(shard.queue, replayEntry{signature, expiry})
+	if expiry.Before(shard.earliest) {
+		shard.earliest = expiry
 	}
 
-	bannedSignatures[0] = append(bannedSignatures[0], signature)
-	sort.Strings(bannedSignatures[0])
+	return false
+}
 
-	return
+// IsBanned checks if the signature is currently banned.
+func (store *MemoryReplayStore) IsBanned(signature string) bool {
+	now := store.clock.Now()
+	shard := store.shard(signature)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	expiry, ok := shard.expiries[signature]
+	return ok && now.Before(expiry)
 }
 
-// IsSignatureBanned checks if the given signature is banned.
-func IsSignatureBanned(signature string) bool {
-	if len(signature) == 0 {
-		return true // empty signature is always banned
+// Len returns the number of signatures held, including any which have expired
+// but have not been evicted yet.
+func (store *MemoryReplayStore) Len() (length int) {
+	for i := range store.shards {
+		shard := &store.shards[i]
+		shard.mutex.Lock()
+		length += len(shard.expiries)
+		shard.mutex.Unlock()
 	}
+	return length
+}
 
-	bannedMutex.RLock()
-	defer bannedMutex.RUnlock()
+// evict removes expired signatures from the front of the queue, and then the
+// oldest signatures until there is room for one more. The queue is in the
+// order the signatures were banned, which is not the order they expire, as
+// responses are only banned until their challenge expires. So before a
+// signature which is still banned is evicted, the rest of the queue is
+// searched for expired signatures, when any could have expired.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (shard *replayShard) evict(now time.Time) {
+	for shard.head < len(shard.queue) && !now.Before(shard.queue[shard.head].expiry) {
+		shard.removeHead()
+	}
+	if len(shard.expiries) >= shard.maxEntries && !now.Before(shard.earliest) {
+		shard.removeExpired(now)
+	}
+	for shard.head < len(shard.queue) && len(shard.expiries) >= shard.maxEntries {
+		shard.removeHead()
+	}
+
+	// Reclaim the space used by the evicted part of the queue
+	if shard.head > 0 && shard.head*2 >= len(shard.queue) {
+		shard.queue = append(shard.queue[:0], shard.queue[shard.head:]...)
+		shard.head = 0
+	}
+}
 
-	for _, list := range bannedSignatures {
-		for _, entry := range list {
-			if entry == signature {
-				return true
-			}
+// removeHead removes the oldest signature in the queue.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (shard *replayShard) removeHead() {
+	entry := shard.queue[shard.head]
+	if shard.isCurrent(entry) {
+		delete(shard.expiries, entry.signature)
+	}
+	shard.queue[shard.head] = replayEntry{}
+	shard.head++
+}
+
+// removeExpired removes every expired signature from the queue, and finds when
+// the next signature expires.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (shard *replayShard) removeExpired(now time.Time) {
+	kept := shard.queue[:0]
+	shard.earliest = time.Time{}
+	for _, entry := range shard.queue[shard.head:] {
+		if !shard.isCurrent(entry) {
+			continue
+		}
+		if !now.Before(entry.expiry) {
+			delete(shard.expiries, entry.signature)
+			continue
 		}
+		if shard.earliest.IsZero() || entry.expiry.Before(shard.earliest) {
+			shard.earliest = entry.expiry
+		}
+		kept = append(kept, entry)
 	}
+	clear(shard.queue[len(kept):])
+	shard.queue = kept
+	shard.head = 0
+}
 
-	return false
+// isCurrent reports whether the entry is the latest ban of its signature, as
+// the signature may have been banned again since this entry was queued.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (shard *replayShard) isCurrent(entry replayEntry) bool {
+	expiry, ok := shard.expiries[entry.signature]
+	return ok && expiry.Equal(entry.expiry)
+}
+
+// BanSignature adds the given signature to the list of banned signatures, and
+// reports whether it was already banned. The check and the ban happen
+// atomically, so when the same signature is banned concurrently, only one
+// caller sees that it was not already banned.
+func BanSignature(signature string) (alreadyBanned bool) {
+	return defaultService.BanSignature(signature)
 }
 
-func rotateBannedSignatureLists() {
-	bannedMutex.Lock()
-	defer bannedMutex.Unlock()
+// BanSignature adds the given signature to the list of banned signatures, and
+// reports whether it was already banned. See BanSignature for details.
+func (service *Service) BanSignature(signature string) (alreadyBanned bool) {
+	if len(signature) == 0 {
+		return true // empty signature is always banned
+	}
+
+	return service.replayStore.Ban(signature, service.replayTTL())
+}
 
-	if len(bannedSignatures) == 0 {
-		return
+// banResponse bans the signature of the response, and reports whether it was
+// already banned. Responses with an expiry are only banned until they expire,
+// as they will be rejected after that regardless.
+func (service *Service) banResponse(msg Message) (alreadyBanned bool) {
+	if len(msg.Signature) == 0 {
+		return true // empty signature is always banned
 	}
 
-	if len(bannedSignatures) == 2 && len(bannedSignatures[1]) == 0 && len(bannedSignatures[0]) == 0 {
-		return
+	ttl := service.replayTTL()
+	if expires, ok := msg.Expires(); ok {
+		if untilExpiry := expires.Sub(service.now()); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
 	}
 
-	bannedSignatures = [][]string{
-		make([]string, 0, defaultBanSliceSize),
-		bannedSignatures[0],
+	return service.replayStore.Ban(msg.Signature, ttl)
+}
+
+// IsSignatureBanned checks if the given signature is banned.
+//
+// This must not be used to check for replays before calling BanSignature, as
+// concurrent requests could all pass the check before any of them bans the
+// signature. Use the result of BanSignature instead.
+func IsSignatureBanned(signature string) bool {
+	return defaultService.IsSignatureBanned(signature)
+}
+
+// IsSignatureBanned checks if the given signature is banned. See
+// IsSignatureBanned for details.
+func (service *Service) IsSignatureBanned(signature string) bool {
+	if len(signature) == 0 {
+		return true // empty signature is always banned
 	}
+
+	return service.replayStore.IsBanned(signature)
 }
 
-func init() {
-	AddSecretsRotationCallback(rotateBannedSignatureLists)
+// replayTTL is how long a signature must remain banned. A signature remains
+// valid until the key used to sign it is no longer retained, which takes up to
+// one more rotation interval than the number of retained keys. The longest
+// interval which has been in effect is used, as a key signed before the
+// interval was lengthened is rotated out at the longer interval. Without
+// rotation, when the secrets come from a SecretProvider, or when there are
+// Ed25519 keys, which are not rotated, the signature remains valid
+// indefinitely, so it is banned for as long as the store will hold it.
+func (service *Service) replayTTL() time.Duration {
+	if service.config.Secrets != nil || len(service.publicKeys) > 0 {
+		return maxReplayTTL
+	}
+
+	service.secretsMutex.RLock()
+	interval, longest := service.rotationInterval, service.longestRotationInterval
+	service.secretsMutex.RUnlock()
+
+	if interval <= 0 {
+		return maxReplayTTL
+	}
+	return time.Duration(service.retainedKeys()+1) * longest
 }
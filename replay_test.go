@@ -5,56 +5,61 @@
 package altcha
 
 import (
-	"log"
+	"errors"
+	"github.com/k42-software/go-altcha/clock"
+	"github.com/k42-software/go-altcha/rand"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestBanSignature(t *testing.T) {
-	// Reset bannedSignatures for testing
-	bannedSignatures = [][]string{}
+	// Use a fresh service for testing
+	service := NewService(Config{})
 
 	signature := "testSignature"
-	BanSignature(signature)
+	service.BanSignature(signature)
 
-	if len(bannedSignatures) == 0 || len(bannedSignatures[0]) == 0 || bannedSignatures[0][0] != signature {
+	if !service.IsSignatureBanned(signature) {
 		t.Errorf("BanSignature failed to add signature")
 	}
-
-	log.Printf("bannedSignatures: %v", bannedSignatures)
 }
 
 func TestBanSignatureEmpty(t *testing.T) {
-	// Reset bannedSignatures for testing
-	bannedSignatures = [][]string{}
+	// Use a fresh store for testing
+	store := NewMemoryReplayStore(0)
+	service := NewService(Config{ReplayStore: store})
 
-	BanSignature("")
+	service.BanSignature("")
 
-	if len(bannedSignatures) != 0 {
+	if store.Len() != 0 {
 		t.Errorf("BanSignature should not add empty signature")
 	}
 }
 
 func TestIsSignatureBanned(t *testing.T) {
-	// Reset bannedSignatures for testing
-	bannedSignatures = [][]string{{"bannedSignature"}}
+	// Use a fresh service for testing
+	service := NewService(Config{})
+	service.BanSignature("bannedSignature")
 
-	if !IsSignatureBanned("bannedSignature") {
+	if !service.IsSignatureBanned("bannedSignature") {
 		t.Errorf("IsSignatureBanned failed to recognize a banned signature")
 	}
 
-	if !IsSignatureBanned("") {
+	if !service.IsSignatureBanned("") {
 		t.Errorf("IsSignatureBanned failed to recognize an empty signature")
 	}
 
-	if IsSignatureBanned("unbannedSignature") {
+	if service.IsSignatureBanned("unbannedSignature") {
 		t.Errorf("IsSignatureBanned incorrectly identified an unbanned signature")
 	}
 }
 
 func TestConcurrency(t *testing.T) {
-	// Reset bannedSignatures for testing
-	bannedSignatures = [][]string{}
+	// Use a fresh store for testing
+	store := NewMemoryReplayStore(0)
+	service := NewService(Config{ReplayStore: store})
 
 	var wg sync.WaitGroup
 	signatures := []string{"sig1", "sig2", "sig3"}
@@ -63,13 +68,161 @@ func TestConcurrency(t *testing.T) {
 		wg.Add(1)
 		go func(signature string) {
 			defer wg.Done()
-			BanSignature(signature)
+			service.BanSignature(signature)
 		}(sig)
 	}
 
 	wg.Wait()
 
-	if len(bannedSignatures) == 0 || len(bannedSignatures[0]) != len(signatures) {
+	if store.Len() != len(signatures) {
 		t.Errorf("BanSignature failed to handle concurrent access")
 	}
 }
+
+func TestMemoryReplayStoreBan(t *testing.T) {
+	store := NewMemoryReplayStore(0)
+
+	if store.Ban("signature", time.Minute) {
+		t.Errorf("Expected first ban to report not already banned")
+	}
+	if !store.Ban("signature", time.Minute) {
+		t.Errorf("Expected second ban to report already banned")
+	}
+	if !store.IsBanned("signature") {
+		t.Errorf("Expected signature to be banned")
+	}
+}
+
+func TestMemoryReplayStoreExpiry(t *testing.T) {
+	store := NewMemoryReplayStore(0)
+
+	store.Ban("expired", -time.Second)
+	if store.IsBanned("expired") {
+		t.Errorf("Expected expired signature to not be banned")
+	}
+
+	// Expired signatures can be banned again
+	if store.Ban("expired", time.Minute) {
+		t.Errorf("Expected expired signature to report not already banned")
+	}
+	if !store.IsBanned("expired") {
+		t.Errorf("Expected signature to be banned again")
+	}
+}
+
+func TestMemoryReplayStoreCapacity(t *testing.T) {
+	const capacity = replayStoreShards * 4
+	store := NewMemoryReplayStore(capacity)
+
+	for i := 0; i < capacity*10; i++ {
+		store.Ban("signature"+strconv.Itoa(i), time.Hour)
+	}
+
+	if length := store.Len(); length > capacity {
+		t.Errorf("Expected at most %d signatures, got %d", capacity, length)
+	}
+
+	// The most recent signature must always be held
+	if !store.IsBanned("signature" + strconv.Itoa(capacity*10-1)) {
+		t.Errorf("Expected most recent signature to be banned")
+	}
+}
+
+func TestMemoryReplayStoreEvictsExpired(t *testing.T) {
+	const capacity = replayStoreShards * 4
+	store := NewMemoryReplayStore(capacity)
+
+	for i := 0; i < capacity*10; i++ {
+		store.Ban("expired"+strconv.Itoa(i), -time.Second)
+	}
+
+	// Few enough that they fit even if they all land in the same shard
+	const unexpired = capacity / replayStoreShards
+	for i := 0; i < unexpired; i++ {
+		store.Ban("signature"+strconv.Itoa(i), time.Hour)
+	}
+
+	// None of the unexpired signatures should have been evicted
+	for i := 0; i < unexpired; i++ {
+		if !store.IsBanned("signature" + strconv.Itoa(i)) {
+			t.Errorf("Expected signature%d to be banned", i)
+		}
+	}
+}
+
+func TestMemoryReplayStoreSmallCapacity(t *testing.T) {
+	store := NewMemoryReplayStore(10)
+	for i := 0; i < 100; i++ {
+		store.Ban("signature"+strconv.Itoa(i), time.Hour)
+	}
+	if length := store.Len(); length != 10 {
+		t.Errorf("Expected exactly 10 signatures, got %d", length)
+	}
+}
+
+func TestMemoryReplayStoreEvictsExpiredBeforeOldest(t *testing.T) {
+	fake := clock.NewFake(fakeEpoch)
+	store := NewMemoryReplayStore(3)
+	store.SetClock(fake)
+
+	// The oldest signature expires last, as its challenge had no expiry
+	store.Ban("oldest", time.Hour)
+	store.Ban("short1", time.Minute)
+	store.Ban("short2", time.Minute)
+	fake.Advance(2 * time.Minute)
+
+	// The expired signatures are evicted, rather than the oldest
+	store.Ban("newest", time.Hour)
+	if !store.IsBanned("oldest") || !store.IsBanned("newest") {
+		t.Errorf("Expected the unexpired signatures to be kept")
+	}
+	if length := store.Len(); length != 2 {
+		t.Errorf("Expected the expired signatures to be evicted, got %d signatures", length)
+	}
+}
+
+func TestBanSignatureAlreadyBanned(t *testing.T) {
+	service := NewService(Config{})
+
+	if service.BanSignature("signature") {
+		t.Errorf("Expected first ban to report not already banned")
+	}
+	if !service.BanSignature("signature") {
+		t.Errorf("Expected second ban to report already banned")
+	}
+	if !service.BanSignature("") {
+		t.Errorf("Expected empty signature to report already banned")
+	}
+}
+
+func TestReplayAfterRotationIntervalChanged(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	for name, interval := range map[string]time.Duration{
+		"Disabled":   0,
+		"Lengthened": time.Hour,
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := clock.NewFake(fakeEpoch)
+			service := NewService(Config{Clock: fake})
+			service.Start()
+			defer service.Close()
+			service.SetSecretsRotationInterval(interval)
+
+			msg := service.NewChallenge()
+			msg.Number, _ = msg.Solve(DefaultComplexity)
+			if err := service.VerifyMessage(msg, VerifyOptions{PreventReplay: true}); err != nil {
+				t.Fatalf("Expected response to be valid, got %v", err)
+			}
+
+			// The key is still retained after the default ban would have
+			// ended, so the ban must last longer
+			fake.Advance(11 * time.Minute)
+			if err := service.VerifyMessage(msg, VerifyOptions{PreventReplay: true}); !errors.Is(err, ErrReplay) {
+				t.Errorf("Expected ErrReplay, got %v", err)
+			}
+		})
+	}
+}
@@ -5,48 +5,154 @@
 package altcha
 
 import (
-	"sync"
+	"github.com/k42-software/go-altcha/clock"
 	"time"
 )
 
 const defaultSecretsRotationInterval = 5 * time.Minute
 
-var (
-	currentSecret            string
-	previousSecret           string
-	secretsRotationCallbacks []func()
-	secretsRotationTicker    *time.Ticker
-	secretsMutex             = &sync.RWMutex{}
-)
-
 // GetSecrets returns the current and previous secrets used for the hmac.
 func GetSecrets() (current, previous string) {
-	secretsMutex.RLock()
-	if len(currentSecret) == 0 { // not initialised yet
-		secretsMutex.RUnlock()
-		SetSecretsRotationInterval(defaultSecretsRotationInterval)
-		secretsMutex.RLock()
+	return defaultService.GetSecrets()
+}
+
+// GetSecrets returns the current and previous secrets used for the hmac. When
+// more than one previous key is retained, only the most recent is returned.
+func (service *Service) GetSecrets() (current, previous string) {
+	keys := service.Keys()
+	if len(keys) > 0 {
+		current = keys[0].Secret
+	}
+	if len(keys) > 1 {
+		previous = keys[1].Secret
+	}
+	return current, previous
+}
+
+// Keys returns the keys used for the hmac, newest first. The first key is the
+// current key, which is used for signing.
+func (service *Service) Keys() []Key {
+	if service.config.Secrets != nil {
+		return service.config.Secrets.Keys()
+	}
+
+	service.secretsMutex.RLock()
+	if _, ok := service.keyring.Current(); !ok { // not initialised yet
+		service.secretsMutex.RUnlock()
+		service.Start()
+		service.secretsMutex.RLock()
+	}
+	if service.isDerivingSecrets() && service.secretsEpoch != SecretsEpoch(service.now(), service.secretsEpochLength) {
+		// The epoch has ended, but the scheduled rotation hasn't run yet.
+		// Rotate now so that every instance agrees on the secrets.
+		service.secretsMutex.RUnlock()
+		service.RotateSecrets()
+		service.secretsMutex.RLock()
+	}
+	defer service.secretsMutex.RUnlock()
+	return service.keyring.Keys()
+}
+
+// currentKey returns the key used for signing.
+func (service *Service) currentKey() Key {
+	keys := service.Keys()
+	if len(keys) == 0 {
+		return Key{}
+	}
+	return keys[0]
+}
+
+func (service *Service) isDerivingSecrets() bool {
+	return len(service.config.MasterKey) > 0
+}
+
+// Start starts the automatic rotation of the secrets, if it is enabled.
+//
+// Calling Start is optional, as it is called when the secrets are first used,
+// but calling it explicitly means the secrets are generated and the rotation
+// is running before the first request is handled. Call Close to stop the
+// rotation when the service is no longer needed. Start has no effect if the
+// service has already been started or closed, or uses a SecretProvider.
+func (service *Service) Start() {
+	if service.config.Secrets != nil {
+		return
 	}
-	defer secretsMutex.RUnlock()
-	return currentSecret, previousSecret
+
+	service.secretsMutex.Lock()
+	defer service.secretsMutex.Unlock()
+	if _, ok := service.keyring.Current(); !ok { // not initialised yet
+		service.setSecretsRotationInterval(service.secretsRotationInterval())
+	}
+}
+
+// Close stops the automatic rotation of the secrets, and waits for any
+// rotation callbacks which are running to return. This ensures that no
+// goroutines started by the service are left running, such as at the end of
+// a test or during a graceful shutdown.
+//
+// The service can still be used after it has been closed, but the secrets are
+// no longer rotated automatically. Close must not be called from a rotation
+// callback, as it would wait for itself. The error is always nil, and is
+// returned so that the service is an io.Closer.
+func (service *Service) Close() error {
+	service.secretsMutex.Lock()
+	service.closed = true
+	service.stopRotation()
+	service.secretsMutex.Unlock()
+
+	service.background.Wait()
+	return nil
 }
 
 // RotateSecrets immediately generates a new secret and replaces the previous
 // secret with the current secret. This is concurrency safe and will block
 // until complete.
 func RotateSecrets() {
-	secretsMutex.Lock()
-	defer secretsMutex.Unlock()
-	rotateSecrets()
+	defaultService.RotateSecrets()
+}
+
+// RotateSecrets immediately generates a new secret and replaces the previous
+// secret with the current secret. This is concurrency safe and will block
+// until complete. This has no effect when the service uses a SecretProvider.
+func (service *Service) RotateSecrets() {
+	if service.config.Secrets != nil {
+		return
+	}
+
+	service.secretsMutex.Lock()
+	defer service.secretsMutex.Unlock()
+	service.rotateSecrets()
 }
 
 // WARNING: Ensure the mutex is locked before calling this function.
-func rotateSecrets() {
-	previousSecret = currentSecret
-	currentSecret = randomString(32)
+func (service *Service) rotateSecrets() {
+	if service.isDerivingSecrets() {
+		epoch := SecretsEpoch(service.now(), service.secretsEpochLength)
+		if _, ok := service.keyring.Current(); ok && epoch == service.secretsEpoch {
+			return // the secrets only change at the end of the epoch
+		}
+		service.secretsEpoch = epoch
+		keys := make([]Key, service.retainedKeys()+1)
+		for i := range keys {
+			keys[i] = NewKey(DeriveSecret(service.config.MasterKey, epoch-int64(i)))
+		}
+		service.keyring.set(keys)
+	} else {
+		service.keyring.Add(NewKey(service.randomString(32)))
+	}
 
-	callbacks := secretsRotationCallbacks // copy the slice
+	callbacks := service.secretsRotationCallbacks // copy the slice
+	if len(callbacks) == 0 {
+		return
+	}
+	tracked := !service.closed // Close waits for tracked callbacks to return
+	if tracked {
+		service.background.Add(1)
+	}
 	go func() {
+		if tracked {
+			defer service.background.Done()
+		}
 		for _, callback := range callbacks {
 			callback()
 		}
@@ -56,31 +162,140 @@ func rotateSecrets() {
 // SetSecretsRotationInterval sets the interval at which secrets are automatically
 // rotated. Setting the interval to 0 will disable automatic rotation.
 func SetSecretsRotationInterval(interval time.Duration) {
-	secretsMutex.Lock()
-	defer secretsMutex.Unlock()
-	if secretsRotationTicker != nil {
-		secretsRotationTicker.Stop()
-	}
-	if interval > 0 {
-		if len(currentSecret) == 0 { // not initialised yet
-			currentSecret = randomString(32)
+	defaultService.SetSecretsRotationInterval(interval)
+}
+
+// SetSecretsRotationInterval sets the interval at which secrets are automatically
+// rotated. Setting the interval to 0 will disable automatic rotation. This has
+// no effect when the service uses a SecretProvider.
+func (service *Service) SetSecretsRotationInterval(interval time.Duration) {
+	if service.config.Secrets != nil {
+		return
+	}
+
+	service.secretsMutex.Lock()
+	defer service.secretsMutex.Unlock()
+	service.setSecretsRotationInterval(interval)
+}
+
+// setSecretsRotationInterval initialises the secrets if needed, and replaces
+// any running rotation with one using the given interval. No rotation is
+// started once the service has been closed.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (service *Service) setSecretsRotationInterval(interval time.Duration) {
+	service.stopRotation()
+
+	service.rotationInterval = interval
+	if interval > service.longestRotationInterval {
+		service.longestRotationInterval = interval
+	}
+
+	if service.isDerivingSecrets() {
+		if interval != service.secretsEpochLength {
+			service.secretsEpochLength = interval
+			service.keyring.set(nil) // force the secrets to be derived again
+		}
+		service.rotateSecrets()
+		if interval > 0 && !service.closed {
+			service.scheduleEpochRotation()
 		}
-		rotateSecrets()
-		secretsRotationTicker = time.NewTicker(interval)
-		go func() {
-			defer secretsRotationTicker.Stop()
-			for range secretsRotationTicker.C {
-				RotateSecrets()
+		return
+	}
+
+	if _, ok := service.keyring.Current(); !ok { // not initialised yet
+		service.keyring.Add(NewKey(service.randomString(32)))
+		service.rotateSecrets()
+	} else if interval > 0 {
+		service.rotateSecrets()
+	}
+	if interval > 0 && !service.closed {
+		service.startTicker(interval)
+	}
+}
+
+// startTicker starts a goroutine which rotates the secrets at the given
+// interval, until stopRotation is called.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (service *Service) startTicker(interval time.Duration) {
+	ticker := service.clock().NewTicker(interval)
+	stop := make(chan struct{})
+	service.secretsRotationStop = stop
+
+	service.background.Add(1)
+	go func() {
+		defer service.background.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C():
+				service.rotateOnTick(stop)
 			}
-		}()
+		}
+	}()
+}
+
+// rotateOnTick rotates the secrets, unless the ticker was stopped while
+// waiting for the mutex.
+func (service *Service) rotateOnTick(stop chan struct{}) {
+	service.secretsMutex.Lock()
+	defer service.secretsMutex.Unlock()
+	select {
+	case <-stop:
+	default:
+		service.rotateSecrets()
+	}
+}
+
+// stopRotation stops any running automatic rotation.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (service *Service) stopRotation() {
+	if service.secretsRotationStop != nil {
+		close(service.secretsRotationStop)
+		service.secretsRotationStop = nil
+	}
+	if service.secretsRotationTimer != nil {
+		service.secretsRotationTimer.Stop()
+		service.secretsRotationTimer = nil
 	}
 }
 
+// scheduleEpochRotation schedules the secrets to be rotated at the start of
+// the next epoch, and then again at the start of each following epoch.
+//
+// WARNING: Ensure the mutex is locked before calling this function.
+func (service *Service) scheduleEpochRotation() {
+	next := secretsEpochStart(service.secretsEpoch+1, service.secretsEpochLength)
+
+	var timer clock.Timer
+	timer = service.clock().AfterFunc(next.Sub(service.now()), func() {
+		service.secretsMutex.Lock()
+		defer service.secretsMutex.Unlock()
+		if service.secretsRotationTimer != timer {
+			return // stopped or replaced
+		}
+		service.rotateSecrets()
+		service.scheduleEpochRotation()
+	})
+	service.secretsRotationTimer = timer
+}
+
 // AddSecretsRotationCallback adds a callback function which is called when the
 // secrets are rotated. It is run in a separate goroutine, so that the mutex
 // is not held or locked when the callback is run.
 func AddSecretsRotationCallback(callback func()) {
-	secretsMutex.Lock()
-	defer secretsMutex.Unlock()
-	secretsRotationCallbacks = append(secretsRotationCallbacks, callback)
+	defaultService.AddSecretsRotationCallback(callback)
+}
+
+// AddSecretsRotationCallback adds a callback function which is called when the
+// secrets are rotated. It is run in a separate goroutine, so that the mutex
+// is not held or locked when the callback is run.
+func (service *Service) AddSecretsRotationCallback(callback func()) {
+	service.secretsMutex.Lock()
+	defer service.secretsMutex.Unlock()
+	service.secretsRotationCallbacks = append(service.secretsRotationCallbacks, callback)
 }
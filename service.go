@@ -0,0 +1,217 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"github.com/k42-software/go-altcha/clock"
+	"io"
+	"sync"
+	"time"
+)
+
+// Config is the configuration used to create a Service. The zero value is
+// valid and gives the same behaviour as the package level functions.
+type Config struct {
+
+	// Algorithm is the default hashing algorithm used for new challenges.
+	// Supported algorithms are SHA-256, SHA-384, and SHA-512. When empty,
+	// SHA-256 is used.
+	Algorithm string
+
+	// Complexity is the default complexity used for new challenges. When
+	// zero, DefaultComplexity is used.
+	Complexity int
+
+	// Secrets provides the secrets used to sign and verify challenges. When
+	// nil, the service generates its own random secrets, which are rotated
+	// automatically. Use a SecretProvider when multiple instances of a service
+	// need to verify each other's challenges. The service does not rotate
+	// these secrets, so challenges expire after DefaultChallengeTTL, unless
+	// ChallengeTTL is set.
+	Secrets SecretProvider
+
+	// MasterKey is used to derive the secrets, instead of generating them at
+	// random. Time is divided into epochs of SecretsRotationInterval, and the
+	// secret for each epoch is derived from the master key and the epoch
+	// number. This allows multiple instances of a service to independently
+	// derive the same secrets, provided they are configured with the same
+	// master key and rotation interval, and have reasonably accurate clocks.
+	// This has no effect when Secrets is set.
+	MasterKey []byte
+
+	// SecretsRotationInterval is the interval at which the secrets are
+	// automatically rotated. When zero, a default of 5 minutes is used. A
+	// negative value disables automatic rotation. This has no effect when
+	// Secrets is set.
+	SecretsRotationInterval time.Duration
+
+	// RetainedKeys is the number of previous keys which are still accepted
+	// when verifying, after the keys have been rotated. Challenges are valid
+	// until the key used to sign them is no longer retained, which is between
+	// RetainedKeys and RetainedKeys+1 rotation intervals. When zero, one
+	// previous key is retained. This has no effect when Secrets is set.
+	RetainedKeys int
+
+	// ChallengeTTL is how long new challenges are valid for. The expiry is
+	// added to the challenge salt, and is enforced independently of the
+	// rotation of the secrets. When zero, challenges are valid until the
+	// secret used to sign them is rotated out, except when Secrets or a
+	// SigningKey is set, as those keys are not rotated by the service, and
+	// DefaultChallengeTTL is used. A negative value disables the expiry, so
+	// that challenges signed with a key which is not rotated are valid
+	// indefinitely, and only the ReplayStore prevents them being used again.
+	ChallengeTTL time.Duration
+
+	// SigningKey enables Ed25519 signatures. When set, new challenges are
+	// signed using this private key instead of the hmac, so that they can be
+	// verified by services which only have the public key. Challenges signed
+	// using the hmac are still accepted when verifying. The key is not
+	// rotated, so challenges expire after DefaultChallengeTTL, unless
+	// ChallengeTTL is set.
+	SigningKey ed25519.PrivateKey
+
+	// VerificationKeys are Ed25519 public keys which are accepted when
+	// verifying, in addition to the public key of the SigningKey. A service
+	// which only verifies challenges needs only these public keys.
+	VerificationKeys []ed25519.PublicKey
+
+	// AcceptLegacySignatures accepts challenges where only the challenge hash
+	// was signed, as issued by earlier versions of this package. This is only
+	// intended for use while rolling out an upgrade, where previously issued
+	// challenges are still in use. These challenges are not protected against
+	// tampering with the algorithm or the salt parameters, such as the expiry.
+	AcceptLegacySignatures bool
+
+	// ReplayStore records the signatures of responses which have been used.
+	// When nil, a MemoryReplayStore with the default size is used.
+	ReplayStore ReplayStore
+
+	// Clock is used to tell the time, for the expiry of challenges, the
+	// rotation of the secrets, and the eviction of replayed signatures. When
+	// nil, the system time is used. Use a clock.Fake for deterministic tests.
+	Clock clock.Clock
+
+	// Rand is the source of entropy used to generate the salts, the secret
+	// numbers and the secrets. When nil, crypto/rand is used. This should
+	// only be set for deterministic tests. It does not need to be concurrency
+	// safe, as the service serialises reads from it. The reader must never
+	// return an error, as creating a challenge panics if it does, so a finite
+	// reader, such as a bytes.Reader, must hold enough for every challenge.
+	Rand io.Reader
+}
+
+// Service creates and validates challenges. Each Service has its own secrets,
+// replay prevention and configuration, so that multiple independently
+// configured services can be used within the same process.
+type Service struct {
+	config Config
+
+	keyring                  *Keyring
+	publicKeys               []PublicKey
+	secretsRotationCallbacks []func()
+	secretsRotationStop      chan struct{}
+	secretsRotationTimer     clock.Timer
+	secretsEpoch             int64
+	secretsEpochLength       time.Duration
+	rotationInterval         time.Duration // the interval in effect
+	longestRotationInterval  time.Duration // the longest interval which has been in effect
+	secretsMutex             sync.RWMutex
+
+	closed     bool
+	background sync.WaitGroup // rotation goroutines and callbacks
+
+	randMutex sync.Mutex
+
+	replayStore ReplayStore
+}
+
+// NewService creates a new Service using the given configuration.
+func NewService(config Config) *Service {
+	service := &Service{config: config}
+	service.keyring = NewKeyring(service.retainedKeys())
+	service.publicKeys = publicKeysFromConfig(config)
+	service.secretsEpochLength = service.secretsRotationInterval()
+	service.rotationInterval = service.secretsRotationInterval()
+	service.longestRotationInterval = service.rotationInterval
+	service.replayStore = config.ReplayStore
+	if service.replayStore == nil {
+		store := NewMemoryReplayStore(0)
+		store.SetClock(service.clock())
+		service.replayStore = store
+	}
+	return service
+}
+
+// defaultService is used by the package level functions.
+var defaultService = NewService(Config{})
+
+// DefaultService returns the Service used by the package level functions.
+func DefaultService() *Service {
+	return defaultService
+}
+
+// Clock returns the clock used by the service, which is the system clock
+// unless another is configured.
+func (service *Service) Clock() clock.Clock {
+	return service.clock()
+}
+
+func (service *Service) clock() clock.Clock {
+	if service.config.Clock == nil {
+		return clock.System
+	}
+	return service.config.Clock
+}
+
+func (service *Service) now() time.Time {
+	return service.clock().Now()
+}
+
+func (service *Service) secretsRotationInterval() time.Duration {
+	if service.config.SecretsRotationInterval == 0 {
+		return defaultSecretsRotationInterval
+	}
+	return service.config.SecretsRotationInterval
+}
+
+// DefaultChallengeTTL is how long new challenges are valid for, when the keys
+// which sign them are not rotated by the service, and no ChallengeTTL is
+// configured.
+const DefaultChallengeTTL = 10 * time.Minute
+
+// challengeTTL returns how long new challenges are valid for, or zero when
+// they do not expire.
+func (service *Service) challengeTTL() time.Duration {
+	switch {
+	case service.config.ChallengeTTL < 0:
+		return 0
+	case service.config.ChallengeTTL == 0 && (service.config.Secrets != nil || service.config.SigningKey != nil):
+		return DefaultChallengeTTL
+	}
+	return service.config.ChallengeTTL
+}
+
+func (service *Service) retainedKeys() int {
+	if service.config.RetainedKeys <= 0 {
+		return 1
+	}
+	return service.config.RetainedKeys
+}
+
+// populate fills in any parameters which are missing, using the defaults from
+// the service configuration.
+func (service *Service) populate(params *Parameters) {
+	if len(params.Algorithm) == 0 {
+		params.Algorithm = service.config.Algorithm
+	}
+	if params.Complexity == 0 {
+		params.Complexity = service.config.Complexity
+	}
+	if ttl := service.challengeTTL(); params.Expires.IsZero() && ttl > 0 {
+		params.Expires = service.now().Add(ttl)
+	}
+	params.populateWith(service.randomInt, service.randomString)
+}
@@ -0,0 +1,78 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"github.com/k42-software/go-altcha/rand"
+	"testing"
+)
+
+func TestServiceIndependence(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	first := NewService(Config{Complexity: 5000})
+	second := NewService(Config{Complexity: 5000})
+
+	// Solve a challenge created by the first service
+	response, ok := SolveChallenge(first.NewChallengeEncoded(), 5000)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+
+	// The second service has different secrets, so must reject it
+	if second.ValidateResponse(response, true) {
+		t.Error("Expected response to be rejected by a different service, got true")
+	}
+
+	// The first service must accept it, once
+	if !first.ValidateResponse(response, true) {
+		t.Error("Expected response to be accepted by the issuing service, got false")
+	}
+	if first.ValidateResponse(response, true) {
+		t.Error("Expected replayed response to be rejected, got true")
+	}
+
+	// Banning a signature in one service does not affect another
+	first.BanSignature("independentSignature")
+	if second.IsSignatureBanned("independentSignature") {
+		t.Error("Expected banned signature to be scoped to the service")
+	}
+}
+
+func TestServiceConfigDefaults(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{
+		Algorithm:               "SHA-512",
+		Complexity:              2000,
+		SecretsRotationInterval: -1,
+	})
+
+	msg := service.NewChallenge()
+	if msg.Algorithm != "SHA-512" {
+		t.Errorf("Expected configured algorithm SHA-512, got %s", msg.Algorithm)
+	}
+
+	// The number must be within the configured complexity
+	number, ok := msg.Solve(2000)
+	if !ok {
+		t.Fatalf("Expected challenge to be solvable within the configured complexity")
+	}
+	msg.Number = number
+
+	if !service.IsValidResponse(msg) {
+		t.Error("Expected solved challenge to be valid")
+	}
+
+	// Secrets are still available when automatic rotation is disabled
+	current, previous := service.GetSecrets()
+	if current == "" || previous == "" {
+		t.Error("One or more secrets were empty")
+	}
+}
@@ -8,12 +8,35 @@ import (
 	"crypto/hmac"
 	"encoding/base64"
 	"hash"
+	"strings"
 )
 
 // Sign generates a signature for the given text.
 func Sign(algo Algorithm, text string) string {
-	secret, _ := GetSecrets()
-	return sign(algo, text, secret)
+	return defaultService.Sign(algo, text)
+}
+
+// Sign generates a signature for the given text, using the current key. When
+// the service has an Ed25519 signing key, that is used instead of the hmac,
+// and the algorithm is ignored.
+func (service *Service) Sign(algo Algorithm, text string) string {
+	_, signer := service.signer()
+	return signer(algo, text)
+}
+
+// signer returns the ID of the key used for signing, and a function which
+// signs using it.
+func (service *Service) signer() (id string, signer func(algo Algorithm, text string) string) {
+	if service.config.SigningKey != nil {
+		return service.publicKeys[0].ID, func(_ Algorithm, text string) string {
+			return signEd25519(text, service.config.SigningKey)
+		}
+	}
+
+	key := service.currentKey()
+	return key.ID, func(algo Algorithm, text string) string {
+		return sign(algo, text, key.Secret)
+	}
 }
 
 func sign(algo Algorithm, text, secret string) string {
@@ -34,24 +57,97 @@ func sign(algo Algorithm, text, secret string) string {
 	return base64.RawURLEncoding.EncodeToString(signer.Sum(nil))
 }
 
+// verify checks the signature using the given key.
+func verify(algo Algorithm, text, signature string, key Key) bool {
+	if len(key.Secret) == 0 {
+		return false
+	}
+	validSignature := sign(algo, text, key.Secret)
+	return hmac.Equal([]byte(signature), []byte(validSignature))
+}
+
+// signatureVersion is included in the signed data for challenges, so that
+// the format of the signed data can be changed in future without ambiguity.
+const signatureVersion = "altcha-go/v1"
+
+// signingInput returns the canonical data which is signed for a challenge.
+// It covers everything the server sends to the client, except the signature,
+// so that no part of the challenge can be changed without detection.
+//
+// The fields are separated by newlines. The salt is the only field which can
+// contain a newline, and as every other field has a fixed format, there is no
+// ambiguity about where the salt begins and ends.
+func signingInput(message Message) string {
+	return strings.Join([]string{
+		signatureVersion,
+		message.Algorithm,
+		message.Salt,
+		message.Challenge,
+	}, "\n")
+}
+
+// signChallenge generates the signature for a challenge, using the given key.
+// The ID of the key must already be in the salt of the challenge.
+func signChallenge(algo Algorithm, message Message, key Key) string {
+	return sign(algo, signingInput(message), key.Secret)
+}
+
+// verifyChallenge checks the signature of a challenge.
+//
+// The key used to sign the challenge is found using the key ID in the salt.
+// Challenges without a key ID were issued by earlier versions of this package,
+// so each of the keys is tried in turn. Challenges where only the challenge
+// hash was signed are only accepted when the service is configured to accept
+// legacy signatures. Bound challenges are only accepted with the same binding
+// they were signed with.
+func (service *Service) verifyChallenge(algo Algorithm, message Message, binding Binding) bool {
+	if len(message.Signature) == 0 {
+		return false
+	}
+
+	input := boundSigningInput(message, binding)
+
+	if id := message.SaltParams().Get("kid"); len(id) > 0 {
+		if publicKey, ok := service.lookupPublicKey(id); ok {
+			return verifyEd25519(input, message.Signature, publicKey.Key)
+		}
+		key, ok := lookupKey(service.Keys(), id)
+		return ok && verify(algo, input, message.Signature, key)
+	}
+
+	for _, key := range service.Keys() {
+		if verify(algo, input, message.Signature, key) {
+			return true
+		}
+		if service.config.AcceptLegacySignatures && verify(algo, message.Challenge, message.Signature, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // VerifySignature checks if the given signature is valid for the given text.
 func VerifySignature(algo Algorithm, text string, signature string) (valid bool) {
+	return defaultService.VerifySignature(algo, text, signature)
+}
+
+// VerifySignature checks if the given signature is valid for the given text,
+// using any of the keys, including the Ed25519 public keys.
+func (service *Service) VerifySignature(algo Algorithm, text string, signature string) (valid bool) {
 	if len(signature) == 0 {
 		return false
 	}
 
-	current, previous := GetSecrets()
-
-	// Check using the current secret
-	validSignature := sign(algo, text, current)
-	if signature == validSignature {
-		return true
+	for _, publicKey := range service.publicKeys {
+		if verifyEd25519(text, signature, publicKey.Key) {
+			return true
+		}
 	}
 
-	// Check using the previous secret
-	validSignature = sign(algo, text, previous)
-	if signature == validSignature {
-		return true
+	for _, key := range service.Keys() {
+		if verify(algo, text, signature, key) {
+			return true
+		}
 	}
 
 	return false
@@ -5,6 +5,7 @@
 package altcha
 
 import (
+	"github.com/k42-software/go-altcha/rand"
 	"sync/atomic"
 	"testing"
 )
@@ -209,3 +210,52 @@ func TestSignPanicOnEmptySecret(t *testing.T) {
 	// Call Sign with valid parameters, expecting a panic due to empty secret
 	_ = Sign(SHA256, "test text")
 }
+
+func TestVerifyChallengeEnvelope(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{})
+	msg := service.NewChallenge()
+
+	if !service.verifyChallenge(SHA256, msg, Binding{}) {
+		t.Fatalf("Expected challenge signature to be valid")
+	}
+
+	// Every part of the challenge is covered by the signature
+	tampered := []Message{msg, msg, msg}
+	tampered[0].Algorithm = "SHA-512"
+	tampered[1].Salt = msg.Salt + "?expires=4102444800"
+	tampered[2].Challenge = generateHash(SHA256, "different_salt", 1234)
+	for _, tamperedMsg := range tampered {
+		if service.verifyChallenge(SHA256, tamperedMsg, Binding{}) {
+			t.Errorf("Expected tampered challenge to be rejected: %+v", tamperedMsg)
+		}
+	}
+}
+
+func TestVerifyChallengeLegacy(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	secrets := StaticSecret("0V5xzYiSFmY1swbbkwIoAgbWaiw7yJvZ")
+	strict := NewService(Config{Secrets: secrets})
+	legacy := NewService(Config{Secrets: secrets, AcceptLegacySignatures: true})
+
+	// Sign a challenge the way that earlier versions did, without a key ID
+	msg := Message{
+		Algorithm: "SHA-256",
+		Salt:      "0V5xzYiSFmY1swbb",
+		Challenge: generateHash(SHA256, "0V5xzYiSFmY1swbb", 34000),
+	}
+	msg.Signature = strict.Sign(SHA256, msg.Challenge)
+
+	if strict.verifyChallenge(SHA256, msg, Binding{}) {
+		t.Error("Expected legacy signature to be rejected by default")
+	}
+	if !legacy.verifyChallenge(SHA256, msg, Binding{}) {
+		t.Error("Expected legacy signature to be accepted when enabled")
+	}
+}
@@ -0,0 +1,166 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// solveBlockSize is the number of candidates which a worker checks at a time,
+// between checking whether the search has finished.
+const solveBlockSize = 1024
+
+const defaultProgressInterval = 100 * time.Millisecond
+
+// SolveOptions are the options used by SolveContext.
+type SolveOptions struct {
+
+	// MaximumComplexity is the largest number which is tried. When zero or
+	// less, twice the DefaultComplexity is used.
+	MaximumComplexity int
+
+	// Workers is the number of goroutines used to search for the solution.
+	// When zero or less, GOMAXPROCS goroutines are used.
+	Workers int
+
+	// Progress is called periodically while searching, with the number of
+	// candidates which have been checked so far, and the maximum complexity.
+	// Calls are never concurrent. When nil, progress is not reported.
+	Progress func(checked, maximum int)
+
+	// ProgressInterval is the minimum time between calls to Progress. When
+	// zero, progress is reported at most every 100 milliseconds.
+	ProgressInterval time.Duration
+}
+
+// Solution is the result of solving a challenge.
+type Solution struct {
+
+	// Number is the solution to the challenge.
+	Number int
+
+	// Elapsed is how long it took to find the solution.
+	Elapsed time.Duration
+}
+
+// SolveContext attempts to solve the challenge within the maximum complexity.
+//
+// The numbers are split into blocks, which are searched by a number of worker
+// goroutines in parallel. The search stops when the solution is found, when
+// the maximum complexity is reached, in which case ErrNoSolution is returned,
+// or when the context is done, in which case the error from the context is
+// returned.
+func (message Message) SolveContext(ctx context.Context, options SolveOptions) (solution Solution, err error) {
+	start := time.Now()
+
+	algo, ok := AlgorithmFromString(message.Algorithm)
+	if !ok {
+		return solution, ErrUnsupportedAlgorithm
+	}
+
+	maximum := options.MaximumComplexity
+	if maximum <= 0 {
+		maximum = DefaultComplexity * 2
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if blocks := (maximum + solveBlockSize - 1) / solveBlockSize; workers > blocks {
+		workers = blocks
+	}
+	progress := newSolveProgress(options, maximum)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next  atomic.Int64 // first number of the next block to search
+		found atomic.Int64 // the solution, when found
+		wg    sync.WaitGroup
+	)
+	next.Store(1)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				first := int(next.Add(solveBlockSize) - solveBlockSize)
+				if first > maximum {
+					return
+				}
+				last := first + solveBlockSize - 1
+				if last > maximum {
+					last = maximum
+				}
+				for number := first; number <= last; number++ {
+					if message.Challenge == generateHash(algo, message.Salt, number) {
+						found.Store(int64(number))
+						cancel()
+						return
+					}
+				}
+				progress.add(last - first + 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if number := found.Load(); number > 0 {
+		return Solution{Number: int(number), Elapsed: time.Since(start)}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return solution, err
+	}
+	return solution, ErrNoSolution
+}
+
+// solveProgress counts the candidates checked, and reports the progress.
+type solveProgress struct {
+	report   func(checked, maximum int)
+	interval time.Duration
+	maximum  int
+
+	checked atomic.Int64
+
+	mutex      sync.Mutex
+	lastReport time.Time
+}
+
+func newSolveProgress(options SolveOptions, maximum int) *solveProgress {
+	progress := &solveProgress{
+		report:     options.Progress,
+		interval:   options.ProgressInterval,
+		maximum:    maximum,
+		lastReport: time.Now(),
+	}
+	if progress.interval <= 0 {
+		progress.interval = defaultProgressInterval
+	}
+	return progress
+}
+
+func (progress *solveProgress) add(checked int) {
+	total := int(progress.checked.Add(int64(checked)))
+	if progress.report == nil {
+		return
+	}
+
+	// Skip reporting if another worker is already doing so
+	if !progress.mutex.TryLock() {
+		return
+	}
+	defer progress.mutex.Unlock()
+
+	if now := time.Now(); now.Sub(progress.lastReport) >= progress.interval || total >= progress.maximum {
+		progress.lastReport = now
+		progress.report(total, progress.maximum)
+	}
+}
@@ -0,0 +1,93 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func solveTestMessage(number int) Message {
+	salt := "0V5xzYiSFmY1swbb"
+	return Message{Algorithm: "SHA-256", Salt: salt, Challenge: generateHash(SHA256, salt, number)}
+}
+
+func TestSolveContext(t *testing.T) {
+	for _, workers := range []int{1, 3, 8} {
+		for _, number := range []int{1, solveBlockSize, solveBlockSize + 1, 54321} {
+			solution, err := solveTestMessage(number).SolveContext(context.Background(), SolveOptions{
+				MaximumComplexity: 60000,
+				Workers:           workers,
+			})
+			if err != nil || solution.Number != number {
+				t.Errorf("workers=%d: SolveContext() = %d, %v; want %d", workers, solution.Number, err, number)
+			}
+			if solution.Elapsed <= 0 {
+				t.Errorf("workers=%d: expected the elapsed time to be recorded", workers)
+			}
+		}
+	}
+}
+
+func TestSolveContextNoSolution(t *testing.T) {
+	_, err := solveTestMessage(5000).SolveContext(context.Background(), SolveOptions{MaximumComplexity: 4999})
+	if !errors.Is(err, ErrNoSolution) {
+		t.Errorf("Expected ErrNoSolution, got %v", err)
+	}
+
+	msg := solveTestMessage(1)
+	msg.Algorithm = "MD5"
+	if _, err := msg.SolveContext(context.Background(), SolveOptions{}); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestSolveContextCancel(t *testing.T) {
+	unsolvable := Message{Algorithm: "SHA-256", Salt: "0V5xzYiSFmY1swbb", Challenge: "unsolvable"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := unsolvable.SolveContext(ctx, SolveOptions{MaximumComplexity: 1 << 40}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := unsolvable.SolveContext(ctx, SolveOptions{MaximumComplexity: 1 << 40}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the search to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestSolveContextProgress(t *testing.T) {
+	var calls, last atomic.Int64
+	_, err := solveTestMessage(50000).SolveContext(context.Background(), SolveOptions{
+		MaximumComplexity: 60000,
+		Workers:           1,
+		ProgressInterval:  time.Nanosecond,
+		Progress: func(checked, maximum int) {
+			if maximum != 60000 {
+				t.Errorf("Progress maximum = %d, want 60000", maximum)
+			}
+			if int64(checked) < last.Load() {
+				t.Errorf("Progress went backwards from %d to %d", last.Load(), checked)
+			}
+			last.Store(int64(checked))
+			calls.Add(1)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() == 0 {
+		t.Error("Expected progress to be reported")
+	}
+}
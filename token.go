@@ -0,0 +1,170 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// DefaultPassTokenTTL is how long a pass token is valid for, when no ttl is
+// given.
+const DefaultPassTokenTTL = time.Minute
+
+// PassClaims are the claims of a pass token, which state that a response was
+// verified, when, and for which scope.
+type PassClaims struct {
+
+	// ID uniquely identifies the verified response. It is derived from the
+	// signature of the challenge, so that it does not reveal the signature.
+	ID string `json:"jti"`
+
+	// Challenge is the challenge hash of the verified response.
+	Challenge string `json:"challenge"`
+
+	// Scope is what the pass token is for, such as a form or a route.
+	Scope string `json:"scope,omitempty"`
+
+	// IssuedAt is when the response was verified, in seconds since the Unix
+	// epoch.
+	IssuedAt int64 `json:"iat"`
+
+	// ExpiresAt is when the pass token expires, in seconds since the Unix
+	// epoch.
+	ExpiresAt int64 `json:"exp"`
+}
+
+// passTokenHeader is the JWS protected header of a pass token.
+type passTokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// IssuePassToken issues a pass token for a response which has been verified.
+// See Service.IssuePassToken for details.
+func IssuePassToken(msg Message, scope string, ttl time.Duration) (token string, err error) {
+	return defaultService.IssuePassToken(msg, scope, ttl)
+}
+
+// IssuePassToken issues a short-lived pass token for a response which has been
+// verified, such as by Verify, so that another service can trust that the
+// response was verified without verifying it again, or sharing the replay
+// store. The response must have been verified before calling this.
+//
+// The pass token is a JSON Web Token, signed using the current key of the
+// service: with HS256 for the hmac, or EdDSA when the service has an Ed25519
+// signing key. The ID of the key is in the kid header. When the ttl is zero,
+// DefaultPassTokenTTL is used.
+//
+// A pass token can be used any number of times until it expires, so the ttl
+// should be as short as possible.
+func (service *Service) IssuePassToken(msg Message, scope string, ttl time.Duration) (token string, err error) {
+	if len(msg.Signature) == 0 {
+		return "", errors.Wrap(ErrMalformed, "response has no signature")
+	}
+	if ttl <= 0 {
+		ttl = DefaultPassTokenTTL
+	}
+
+	id := sha256.Sum256([]byte("altcha pass token\n" + msg.Signature))
+	now := service.now()
+	claims := PassClaims{
+		ID:        base64.RawURLEncoding.EncodeToString(id[:12]),
+		Challenge: msg.Challenge,
+		Scope:     scope,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	keyID, signer := service.signer()
+	header := passTokenHeader{Algorithm: "HS256", Type: "JWT", KeyID: keyID}
+	if service.config.SigningKey != nil {
+		header.Algorithm = "EdDSA"
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	return input + "." + signer(SHA256, input), nil
+}
+
+// VerifyPassToken verifies a pass token. See Service.VerifyPassToken for
+// details.
+func VerifyPassToken(token, scope string) (claims PassClaims, err error) {
+	return defaultService.VerifyPassToken(token, scope)
+}
+
+// VerifyPassToken verifies a pass token issued by IssuePassToken, and returns
+// its claims. The service only needs the keys used to sign the pass token,
+// which for EdDSA are the Ed25519 public keys.
+//
+// The returned error can be matched using errors.Is against ErrMalformed,
+// ErrBadSignature, ErrExpired, and ErrWrongScope when the pass token was
+// issued for a different scope.
+func (service *Service) VerifyPassToken(token, scope string) (claims PassClaims, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.Wrap(ErrMalformed, "pass token must have three parts")
+	}
+
+	var header passTokenHeader
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return claims, err
+	}
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return claims, err
+	}
+
+	// The algorithm must match the type of the key, so that a public key can
+	// never be used as an hmac secret.
+	input := parts[0] + "." + parts[1]
+	switch header.Algorithm {
+	case "EdDSA":
+		key, ok := service.lookupPublicKey(header.KeyID)
+		if !ok || !verifyEd25519(input, parts[2], key.Key) {
+			return claims, ErrBadSignature
+		}
+	case "HS256":
+		key, ok := lookupKey(service.Keys(), header.KeyID)
+		if !ok || !verify(SHA256, input, parts[2], key) {
+			return claims, ErrBadSignature
+		}
+	default:
+		return claims, ErrUnsupportedAlgorithm
+	}
+
+	if !service.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return claims, ErrExpired
+	}
+	if claims.Scope != scope {
+		return claims, ErrWrongScope
+	}
+
+	return claims, nil
+}
+
+// decodeTokenPart decodes a base64url encoded JSON part of a pass token.
+func decodeTokenPart(part string, target interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.Wrap(ErrMalformed, err.Error())
+	}
+	if err := json.Unmarshal(decoded, target); err != nil {
+		return errors.Wrap(ErrMalformed, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+//  @author: Brian Wojtczak
+//  @copyright: 2024 by Brian Wojtczak
+//  @license: BSD-style license found in the LICENSE file
+
+package altcha
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/k42-software/go-altcha/clock"
+	"github.com/k42-software/go-altcha/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPassToken(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	fake := clock.NewFake(time.Unix(1700000000, 0))
+	service := NewService(Config{Secrets: StaticSecret("secret"), Clock: fake})
+
+	msg := service.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	token, err := service.IssuePassToken(msg, "signup", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts := strings.Split(token, "."); len(parts) != 3 {
+		t.Fatalf("Expected a JWS compact token, got %q", token)
+	}
+
+	claims, err := service.VerifyPassToken(token, "signup")
+	if err != nil {
+		t.Fatalf("Expected pass token to be valid, got %v", err)
+	}
+	if claims.Challenge != msg.Challenge || claims.Scope != "signup" || len(claims.ID) == 0 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if claims.IssuedAt != 1700000000 || claims.ExpiresAt != 1700000060 {
+		t.Errorf("Unexpected times %d, %d", claims.IssuedAt, claims.ExpiresAt)
+	}
+
+	// Another service with the same secret accepts it
+	other := NewService(Config{Secrets: StaticSecret("secret"), Clock: fake})
+	if _, err := other.VerifyPassToken(token, "signup"); err != nil {
+		t.Errorf("Expected other service to accept the pass token, got %v", err)
+	}
+
+	if _, err := service.VerifyPassToken(token, "login"); !errors.Is(err, ErrWrongScope) {
+		t.Errorf("Expected ErrWrongScope, got %v", err)
+	}
+
+	stranger := NewService(Config{Secrets: StaticSecret("another secret"), Clock: fake})
+	if _, err := stranger.VerifyPassToken(token, "signup"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+
+	// Tampering with the claims breaks the signature
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + parts[1][:len(parts[1])-2] + "fQ." + parts[2]
+	if _, err := service.VerifyPassToken(tampered, "signup"); err == nil {
+		t.Error("Expected tampered pass token to be rejected")
+	}
+
+	for _, malformed := range []string{"", "a.b", "!.!.!", "e30.e30"} {
+		if _, err := service.VerifyPassToken(malformed, "signup"); !errors.Is(err, ErrMalformed) {
+			t.Errorf("VerifyPassToken(%q) = %v; expected ErrMalformed", malformed, err)
+		}
+	}
+
+	fake.Advance(time.Minute)
+	if _, err := service.VerifyPassToken(token, "signup"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestPassTokenEd25519(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := NewService(Config{SigningKey: privateKey})
+	verifier := NewService(Config{VerificationKeys: []ed25519.PublicKey{publicKey}})
+
+	msg := issuer.NewChallenge()
+	msg.Number, _ = msg.Solve(DefaultComplexity)
+
+	token, err := issuer.IssuePassToken(msg, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifier.VerifyPassToken(token, "")
+	if err != nil {
+		t.Fatalf("Expected verifier to accept the pass token, got %v", err)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != int64(DefaultPassTokenTTL/time.Second) {
+		t.Errorf("Expected the default ttl, got %+v", claims)
+	}
+
+	// A service without the public key rejects it
+	if _, err := NewService(Config{}).VerifyPassToken(token, ""); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+
+	// A message without a signature cannot be vouched for
+	if _, err := issuer.IssuePassToken(Message{}, "", 0); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed, got %v", err)
+	}
+}
@@ -4,35 +4,111 @@
 
 package altcha
 
+import "github.com/pkg/errors"
+
+// VerifyOptions are the options used when verifying a response.
+type VerifyOptions struct {
+
+	// PreventReplay bans the signature of a successfully verified response,
+	// so that the same response is rejected with ErrReplay if used again.
+	PreventReplay bool
+
+	// Binding is the context of the client which sent the response. When the
+	// challenge was bound, the response is rejected with ErrBindingMismatch
+	// unless the binding is the same as when the challenge was issued. It is
+	// ignored for challenges which were not bound.
+	Binding Binding
+
+	// Scope is what the response is being verified for, such as a form or a
+	// route. When set, the response is rejected with ErrWrongScope if the
+	// challenge was issued for a different scope, or without a scope. When
+	// empty, the scope of the challenge is not checked.
+	Scope string
+
+	// AcceptUnscoped accepts challenges issued without a scope when a Scope
+	// is set. These can be used for any scope, so this should only be set
+	// while moving to scoped challenges.
+	AcceptUnscoped bool
+}
+
 // ValidateResponse decodes and validates the response from the client.
 func ValidateResponse(encoded string, preventReplay bool) (ok bool) {
+	return defaultService.ValidateResponse(encoded, preventReplay)
+}
+
+// ValidateResponse decodes and validates the response from the client.
+func (service *Service) ValidateResponse(encoded string, preventReplay bool) (ok bool) {
+	_, err := service.Verify(encoded, VerifyOptions{PreventReplay: preventReplay})
+	return err == nil
+}
+
+// ValidateMessage validates an already decoded response from the client.
+func ValidateMessage(msg Message, preventReplay bool) (ok bool) {
+	return defaultService.ValidateMessage(msg, preventReplay)
+}
+
+// ValidateMessage validates an already decoded response from the client.
+func (service *Service) ValidateMessage(msg Message, preventReplay bool) (ok bool) {
+	return service.VerifyMessage(msg, VerifyOptions{PreventReplay: preventReplay}) == nil
+}
+
+// Verify decodes and verifies the response from the client. It returns nil
+// when the response is valid, otherwise an error which explains why it was
+// rejected. The error can be matched using errors.Is against ErrMalformed,
+// ErrUnsupportedAlgorithm, ErrBadSolution, ErrBadSignature, ErrBindingMismatch,
+// ErrExpired, ErrWrongScope and ErrReplay.
+func Verify(encoded string, options VerifyOptions) (msg Message, err error) {
+	return defaultService.Verify(encoded, options)
+}
+
+// Verify decodes and verifies the response from the client. See Verify for
+// details.
+func (service *Service) Verify(encoded string, options VerifyOptions) (msg Message, err error) {
 
 	// decode the response
-	msg, err := DecodeResponse(encoded)
+	msg, err = DecodeResponse(encoded)
 	if err != nil {
-		return false
+		return msg, errors.Wrap(ErrMalformed, err.Error())
 	}
 
+	return msg, service.VerifyMessage(msg, options)
+}
+
+// VerifyMessage verifies an already decoded response from the client. See
+// Verify for details.
+func VerifyMessage(msg Message, options VerifyOptions) error {
+	return defaultService.VerifyMessage(msg, options)
+}
+
+// VerifyMessage verifies an already decoded response from the client. See
+// Verify for details.
+func (service *Service) VerifyMessage(msg Message, options VerifyOptions) error {
+
 	// check if the response contains a valid solution to the challenge
-	ok = msg.IsValidResponse()
-	if !ok {
-		return false
+	if err := service.verifySolution(msg, options.Binding); err != nil {
+		return err
+	}
+
+	// check the challenge was issued for the same scope, which can only be
+	// trusted once the signature has been checked
+	if scope := msg.Scope(); len(options.Scope) > 0 && scope != options.Scope {
+		if len(scope) > 0 || !options.AcceptUnscoped {
+			return ErrWrongScope
+		}
 	}
 
 	// skip the rest if replay prevention is not enabled
-	if !preventReplay {
-		return true
+	if !options.PreventReplay {
+		return nil
 	}
 
-	// check if the response is a replay
+	// add the signature to the list of banned signatures, failing if it was
+	// already there, as that means the response is a replay
 	// (only do if this it is valid, so someone can't denial-of-service you by
 	// sending a bunch of invalid responses with valid signatures)
-	if IsSignatureBanned(msg.Signature) {
-		return false
+	if service.banResponse(msg) {
+		return ErrReplay
 	}
 
-	// add the signature to the list of banned signatures
-	BanSignature(msg.Signature)
-
-	return true // Success!
+	return nil // Success!
 }
@@ -5,7 +5,13 @@
 package altcha
 
 import (
+	"errors"
+	"github.com/k42-software/go-altcha/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestValidateChallenge(t *testing.T) {
@@ -25,7 +31,7 @@ func TestValidateChallenge(t *testing.T) {
 		Salt:      "0V5xzYiSFmY1swbb",
 		Number:    49500,
 		Challenge: "69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7",
-		Signature: "-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k",
+		Signature: "Hf_I_Sozmm-GoVrkEJ2QCNumEJq3KnU7_62g6w3DZUs",
 	}
 	if !ValidateResponse(validMsg.EncodeWithBase64(), false) {
 		t.Error("Expected valid encoded message to return true, got false")
@@ -66,13 +72,16 @@ func TestValidateChallengeReplayPrevention(t *testing.T) {
 	RotateSecrets()
 	RotateSecrets()
 
+	// Reset the replay store for testing
+	defaultService.replayStore = NewMemoryReplayStore(0)
+
 	// Generate a valid encoded response
 	validMsg := Message{
 		Algorithm: "SHA-256",
 		Salt:      "0V5xzYiSFmY1swbb",
 		Number:    49500,
 		Challenge: "69df4e03d8fffc1d66aeba60384ad28d70caed4bcf10c69f80e0a16666eae6a7",
-		Signature: "-gytD6e0qjPZknud02kOzq8KqsayfXfGI1exZXFjI6k",
+		Signature: "Hf_I_Sozmm-GoVrkEJ2QCNumEJq3KnU7_62g6w3DZUs",
 	}
 	response := validMsg.EncodeWithBase64()
 
@@ -87,3 +96,179 @@ func TestValidateChallengeReplayPrevention(t *testing.T) {
 	}
 
 }
+
+func TestValidateChallengeConcurrentReplay(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{})
+
+	// Generate a valid encoded response
+	response, ok := SolveChallenge(service.NewChallengeEncoded(), DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+
+	// Submit the same response from many goroutines at once
+	const attempts = 100
+	var wg sync.WaitGroup
+	var successes int32
+	start := make(chan struct{})
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			defer wg.Done()
+			<-start
+			if service.ValidateResponse(response, true) {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("Expected exactly one successful validation, got %d", successes)
+	}
+}
+
+func TestValidateChallengeExpiry(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{ChallengeTTL: time.Minute})
+
+	// Challenges from a service with a TTL carry an expiry
+	challenge := service.NewChallenge()
+	if _, ok := challenge.Expires(); !ok {
+		t.Fatalf("Expected challenge to have an expiry, got salt %s", challenge.Salt)
+	}
+
+	// An unexpired challenge is valid
+	response, ok := SolveChallenge(challenge.Encode(), DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+	if !service.ValidateResponse(response, true) {
+		t.Error("Expected unexpired response to return true, got false")
+	}
+
+	// An expired challenge is rejected, even though it is correctly signed
+	expired := service.NewChallengeWithParams(Parameters{Expires: time.Now().Add(-time.Second)})
+	expired.Number, ok = expired.Solve(DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+	if !expired.IsExpired() {
+		t.Error("Expected challenge to be expired")
+	}
+	if service.ValidateMessage(expired, true) {
+		t.Error("Expected expired response to return false, got true")
+	}
+}
+
+func TestVerifyReasons(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{})
+
+	// Create a valid response to modify
+	valid := service.NewChallenge()
+	var ok bool
+	valid.Number, ok = valid.Solve(DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+
+	expired := service.NewChallengeWithParams(Parameters{Expires: time.Now().Add(-time.Second)})
+	expired.Number, ok = expired.Solve(DefaultComplexity)
+	if !ok {
+		t.Fatalf("could not solve challenge")
+	}
+
+	badAlgorithm := valid
+	badAlgorithm.Algorithm = "MD5"
+	badNumber := valid
+	badNumber.Number = 0
+	badSolution := valid
+	badSolution.Number++
+	badSignature := valid
+	badSignature.Signature = "incorrect_signature"
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{"Malformed", "invalid-base64", ErrMalformed},
+		{"MissingNumber", badNumber.EncodeWithBase64(), ErrMalformed},
+		{"UnsupportedAlgorithm", badAlgorithm.EncodeWithBase64(), ErrUnsupportedAlgorithm},
+		{"BadSolution", badSolution.EncodeWithBase64(), ErrBadSolution},
+		{"BadSignature", badSignature.EncodeWithBase64(), ErrBadSignature},
+		{"Expired", expired.EncodeWithBase64(), ErrExpired},
+		{"Valid", valid.EncodeWithBase64(), nil},
+		{"Replay", valid.EncodeWithBase64(), ErrReplay},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.Verify(tc.encoded, VerifyOptions{PreventReplay: true})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyScope(t *testing.T) {
+
+	randomInt = rand.Int       // Reset randomInt to use the real function
+	randomString = rand.String // Reset randomString to use the real function
+
+	service := NewService(Config{})
+
+	scoped := service.NewChallengeWithParams(Parameters{Scope: "/newsletter"})
+	scoped.Number, _ = scoped.Solve(DefaultComplexity)
+	if scoped.Scope() != "/newsletter" {
+		t.Errorf("Expected the scope in the salt, got %q", scoped.Salt)
+	}
+
+	unscoped := service.NewChallenge()
+	unscoped.Number, _ = unscoped.Solve(DefaultComplexity)
+
+	tests := []struct {
+		name           string
+		msg            Message
+		scope          string
+		acceptUnscoped bool
+		wantErr        error
+	}{
+		{"SameScope", scoped, "/newsletter", false, nil},
+		{"WrongScope", scoped, "/password-reset", false, ErrWrongScope},
+		{"NotChecked", scoped, "", false, nil},
+		{"Unscoped", unscoped, "/password-reset", false, ErrWrongScope},
+		{"UnscopedNotChecked", unscoped, "", false, nil},
+		{"UnscopedAccepted", unscoped, "/password-reset", true, nil},
+		{"WrongScopeNotAccepted", scoped, "/password-reset", true, ErrWrongScope},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := service.VerifyMessage(tc.msg, VerifyOptions{Scope: tc.scope, AcceptUnscoped: tc.acceptUnscoped})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("VerifyMessage() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	// The scope cannot be changed without breaking the signature
+	tampered := service.NewChallengeWithParams(Parameters{Scope: "/newsletter", Number: 1234})
+	tampered.Number = 1234
+	tampered.Salt = strings.Replace(tampered.Salt, "newsletter", "password-reset", 1)
+	tampered.Challenge = generateHash(SHA256, tampered.Salt, tampered.Number)
+	if err := service.VerifyMessage(tampered, VerifyOptions{Scope: "/password-reset"}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+}